@@ -0,0 +1,256 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Standard annotation keys of the OCI image spec
+const (
+	AnnotationCreated    = "org.opencontainers.image.created"
+	AnnotationRevision   = "org.opencontainers.image.revision"
+	AnnotationSource     = "org.opencontainers.image.source"
+	AnnotationVersion    = "org.opencontainers.image.version"
+	AnnotationBaseName   = "org.opencontainers.image.base.name"
+	AnnotationBaseDigest = "org.opencontainers.image.base.digest"
+)
+
+// annotatedImage adds annotations to the manifest of an image and to its layer descriptors
+type annotatedImage struct {
+	v1.Image
+	annotations map[string]string
+	layers      map[v1.Hash]map[string]string
+}
+
+func mergeAnnotations(target map[string]string, annotations map[string]string) map[string]string {
+	if len(annotations) == 0 {
+		return target
+	}
+	if target == nil {
+		target = make(map[string]string)
+	}
+	for k, v := range annotations {
+		target[k] = v
+	}
+	return target
+}
+
+func (a *annotatedImage) Manifest() (*v1.Manifest, error) {
+	manifest, err := a.Image.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	manifest = manifest.DeepCopy()
+	manifest.Annotations = mergeAnnotations(manifest.Annotations, a.annotations)
+	for n, layer := range manifest.Layers {
+		manifest.Layers[n].Annotations = mergeAnnotations(layer.Annotations, a.layers[layer.Digest])
+	}
+	return manifest, nil
+}
+
+func (a *annotatedImage) RawManifest() ([]byte, error) {
+	manifest, err := a.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(manifest)
+}
+
+func (a *annotatedImage) Digest() (v1.Hash, error) {
+	raw, err := a.RawManifest()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	h, _, err := v1.SHA256(bytes.NewReader(raw))
+	return h, err
+}
+
+func (a *annotatedImage) Size() (int64, error) {
+	raw, err := a.RawManifest()
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(raw)), nil
+}
+
+// annotate wraps img with manifest annotations and layer annotations by layer digest
+func annotate(img v1.Image, annotations map[string]string, layers map[v1.Hash]map[string]string) v1.Image {
+	res := &annotatedImage{
+		Image:       img,
+		annotations: make(map[string]string),
+		layers:      make(map[v1.Hash]map[string]string),
+	}
+	if previous, ok := img.(*annotatedImage); ok {
+		res.Image = previous.Image
+		mergeAnnotations(res.annotations, previous.annotations)
+		for digest, values := range previous.layers {
+			res.layers[digest] = mergeAnnotations(nil, values)
+		}
+	}
+	mergeAnnotations(res.annotations, annotations)
+	for digest, values := range layers {
+		res.layers[digest] = mergeAnnotations(res.layers[digest], values)
+	}
+	return res
+}
+
+// useOCIMediaTypes switches a Docker image to OCI manifest, config and layer media types, Docker manifests having no annotations
+func (i *Image) useOCIMediaTypes() error {
+	manifest, err := i.img.Manifest()
+	if err != nil {
+		return err
+	}
+	if manifest.MediaType != types.DockerManifestSchema2 {
+		return nil
+	}
+	i.img = mutate.MediaType(mutate.ConfigMediaType(i.img, types.OCIConfigJSON), types.OCIManifestSchema1)
+	return i.fitMediaTypes()
+}
+
+// Annotate sets annotations on the manifest of the image, switching a Docker image to OCI media types
+func (i *Image) Annotate(annotations map[string]string) error {
+	for k := range annotations {
+		if k == "" {
+			return fmt.Errorf("empty annotation name")
+		}
+	}
+	err := i.useOCIMediaTypes()
+	if err != nil {
+		return err
+	}
+	i.img = annotate(i.img, annotations, nil)
+	return nil
+}
+
+// AnnotateLayer sets annotations on the descriptor of the layer at index, switching a Docker image to OCI media types
+func (i *Image) AnnotateLayer(index int, annotations map[string]string) error {
+	layers, err := i.img.Layers()
+	if err != nil {
+		return err
+	}
+	err = i.checkLayerIndex(index, len(layers))
+	if err != nil {
+		return err
+	}
+	err = i.useOCIMediaTypes()
+	if err != nil {
+		return err
+	}
+	digest, err := layers[index].Digest()
+	if err != nil {
+		return err
+	}
+	i.img = annotate(i.img, nil, map[v1.Hash]map[string]string{digest: annotations})
+	return nil
+}
+
+// StandardAnnotations selects the org.opencontainers.image annotations filled from the run context
+type StandardAnnotations struct {
+	// Version defaults to the git tag of the current commit
+	Version string `json:"version"`
+	// Revision defaults to the current git commit
+	Revision string `json:"revision"`
+	// Source defaults to the URL of the git origin remote
+	Source string `json:"source"`
+	// Labels also sets the annotations as config labels
+	Labels bool `json:"labels"`
+}
+
+// gitOutput returns the trimmed output of a git command in the working directory, empty when it fails
+func gitOutput(args ...string) string {
+	output, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// publicURL strips the credentials of a git remote URL
+func publicURL(remote string) string {
+	parsed, err := url.Parse(remote)
+	if err != nil || parsed.Scheme == "" {
+		return remote
+	}
+	parsed.User = nil
+	return parsed.String()
+}
+
+// AnnotateStandard fills the org.opencontainers.image annotations from the run context:
+// the creation time, the git revision, source and tag of the working directory, and the image this one was loaded as.
+// Values that cannot be found are left unset.
+func (i *Image) AnnotateStandard(options *StandardAnnotations) error {
+	if options == nil {
+		options = &StandardAnnotations{}
+	}
+	created, err := creationTime()
+	if err != nil {
+		return err
+	}
+	annotations := map[string]string{
+		AnnotationCreated: created.Format(time.RFC3339),
+	}
+	values := map[string]string{
+		AnnotationVersion:  options.Version,
+		AnnotationRevision: options.Revision,
+		AnnotationSource:   options.Source,
+	}
+	if values[AnnotationVersion] == "" {
+		values[AnnotationVersion] = gitOutput("describe", "--tags", "--exact-match")
+	}
+	if values[AnnotationRevision] == "" {
+		values[AnnotationRevision] = gitOutput("rev-parse", "HEAD")
+	}
+	if values[AnnotationSource] == "" {
+		if remote := gitOutput("config", "--get", "remote.origin.url"); remote != "" {
+			values[AnnotationSource] = publicURL(remote)
+		}
+	}
+	if i.base != nil {
+		digest, err := i.base.Digest()
+		if err != nil {
+			return err
+		}
+		values[AnnotationBaseName] = i.baseName
+		values[AnnotationBaseDigest] = digest.String()
+	}
+	for k, v := range values {
+		if v != "" {
+			annotations[k] = v
+		}
+	}
+	if options.Labels {
+		err = i.updateConfig(func(config *v1.Config) error {
+			config.Labels = mergeAnnotations(config.Labels, annotations)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return i.Annotate(annotations)
+}
@@ -0,0 +1,150 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func checkOCIManifest(t *testing.T, image *Image) *v1.Manifest {
+	manifest, err := image.img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.MediaType != types.OCIManifestSchema1 || manifest.Config.MediaType != types.OCIConfigJSON {
+		t.Fatalf("expected OCI manifest and config media types, got %s and %s", manifest.MediaType, manifest.Config.MediaType)
+	}
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != types.OCILayer {
+			t.Fatalf("expected OCI layer media types, got %s", layer.MediaType)
+		}
+	}
+	return manifest
+}
+
+func TestAnnotateSwitchesToOCI(t *testing.T) {
+	image := layersImage(t, buildLayer(t, "/a", "a", nil), buildLayer(t, "/b", "b", nil))
+	before, err := image.img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.MediaType != types.DockerManifestSchema2 {
+		t.Fatalf("expected a docker image to start with, got %s", before.MediaType)
+	}
+	err = image.Annotate(map[string]string{"team": "build"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = image.AnnotateLayer(1, map[string]string{"part": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := checkOCIManifest(t, image)
+	if manifest.Annotations["team"] != "build" {
+		t.Errorf("unexpected manifest annotations %v", manifest.Annotations)
+	}
+	for n, layer := range manifest.Layers {
+		if layer.Digest != before.Layers[n].Digest {
+			t.Errorf("layer %d changed from %s to %s", n, before.Layers[n].Digest, layer.Digest)
+		}
+	}
+	if manifest.Layers[1].Annotations["part"] != "b" || len(manifest.Layers[0].Annotations) != 0 {
+		t.Errorf("unexpected layer annotations %v and %v", manifest.Layers[0].Annotations, manifest.Layers[1].Annotations)
+	}
+	// Gzip layers appended afterwards get the OCI media type as well
+	err = image.AddLayer(buildLayer(t, "/c", "c", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest = checkOCIManifest(t, image)
+	if len(manifest.Layers) != 3 || manifest.Annotations["team"] != "build" {
+		t.Errorf("unexpected manifest after append %+v", manifest)
+	}
+}
+
+func TestAnnotateEmptyName(t *testing.T) {
+	image := layersImage(t)
+	if err := image.Annotate(map[string]string{"": "value"}); err == nil {
+		t.Fatal("expected an empty annotation name error")
+	}
+	if err := image.AnnotateLayer(0, map[string]string{"a": "b"}); err == nil {
+		t.Fatal("expected a missing layer error")
+	}
+}
+
+func TestAnnotateStandardBaseName(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	base, err := layersImage(t, buildLayer(t, "/a", "a", nil)).Clone(host + "/base")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = base.Push()
+	if err != nil {
+		t.Fatal(err)
+	}
+	image, err := LoadImage(host + "/base")
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := image.img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = image.AddLayer(buildLayer(t, "/b", "b", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = image.AnnotateStandard(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := checkOCIManifest(t, image)
+	if name := manifest.Annotations[AnnotationBaseName]; name != host+"/base:latest" {
+		t.Errorf("expected the fully qualified base name, got %q", name)
+	}
+	if manifest.Annotations[AnnotationBaseDigest] != digest.String() {
+		t.Errorf("expected base digest %s, got %q", digest, manifest.Annotations[AnnotationBaseDigest])
+	}
+	if manifest.Annotations[AnnotationCreated] == "" {
+		t.Error("no creation annotation")
+	}
+}
+
+func TestQualifiedName(t *testing.T) {
+	image, err := layersImage(t).Clone("alpine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := image.qualifiedName(); name != "index.docker.io/library/alpine:latest" {
+		t.Errorf("unexpected qualified name %q", name)
+	}
+	image, err = image.Clone(LayoutPrefix + "/tmp/layout:alpine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := image.qualifiedName(); name != "" {
+		t.Errorf("expected no qualified name for a layout, got %q", name)
+	}
+}
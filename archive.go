@@ -0,0 +1,270 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// ImportOptions controls how an existing archive is converted to a layer
+type ImportOptions struct {
+	// Dest is the in-image directory the archive root is mapped to, the image root when empty
+	Dest string `json:"dest"`
+	LayerOptions
+}
+
+// archivePath returns the name of an archive entry cleaned relative to the archive root,
+// the names climbing above the root are rejected before they could be rebased under Dest
+func archivePath(name string) (string, error) {
+	clean := path.Clean(strings.TrimLeft(name, "/"))
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("entry %q is outside of the archive", name)
+	}
+	return clean, nil
+}
+
+// entryName returns the in-image name of an archive entry or hard link target
+func (o *ImportOptions) entryName(name string) (string, error) {
+	clean, err := archivePath(name)
+	if err != nil {
+		return "", err
+	}
+	if o == nil {
+		return entryPath(clean), nil
+	}
+	return entryPath(path.Join(o.Dest, clean)), nil
+}
+
+// checkSymlink rejects a relative symlink target climbing above the archive root from the entry name.
+// Absolute targets are left to the image root, as in any layer.
+func checkSymlink(name string, target string) error {
+	if path.IsAbs(target) {
+		return nil
+	}
+	if _, err := archivePath(path.Join(path.Dir(strings.TrimLeft(name, "/")), target)); err != nil {
+		return fmt.Errorf("symlink %q target %q is outside of the archive", name, target)
+	}
+	return nil
+}
+
+func (o *ImportOptions) layerOptions() *LayerOptions {
+	if o == nil {
+		return nil
+	}
+	return &o.LayerOptions
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// openArchive returns the uncompressed content of a plain, gzip or zstd compressed file
+func openArchive(r io.Reader) (io.Reader, func(), error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gr, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, nil, err
+		}
+		return gr, func() { _ = gr.Close() }, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr.Close, nil
+	default:
+		return buffered, func() {}, nil
+	}
+}
+
+// LayerFromTar converts a plain, gzip or zstd compressed tarball to a layer, its entries rebased under the Dest option
+func LayerFromTar(tarPath string, options *ImportOptions) (v1.Layer, error) {
+	epoch, err := options.layerOptions().epoch()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(tarPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	content, closeArchive, err := openArchive(f)
+	if err != nil {
+		return nil, err
+	}
+	defer closeArchive()
+	reader := tar.NewReader(content)
+	return writeLayer("", options.layerOptions(), func(writer *tar.Writer) error {
+		for {
+			th, err := reader.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("reading %s: %v", tarPath, err)
+			}
+			name := th.Name
+			th.Name, err = options.entryName(name)
+			if err != nil {
+				return fmt.Errorf("reading %s: %v", tarPath, err)
+			}
+			if th.Name == "" {
+				continue
+			}
+			if th.Typeflag == tar.TypeRegA {
+				th.Typeflag = tar.TypeReg
+			}
+			switch th.Typeflag {
+			case tar.TypeLink:
+				th.Linkname, err = options.entryName(th.Linkname)
+			case tar.TypeSymlink:
+				err = checkSymlink(name, th.Linkname)
+			}
+			if err != nil {
+				return fmt.Errorf("reading %s: %v", tarPath, err)
+			}
+			err = writer.WriteHeader(normalizeHeader(th, epoch))
+			if err != nil {
+				return err
+			}
+			if th.Typeflag == tar.TypeReg {
+				_, err = io.Copy(writer, reader)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	})
+}
+
+// zipHeader converts a zip entry to a tar header, keeping the unix modes when the archive has them
+func zipHeader(f *zip.File, name string) (*tar.Header, error) {
+	info := f.FileInfo()
+	th := &tar.Header{
+		Name:    name,
+		ModTime: f.Modified,
+		Mode:    headerMode(info.Mode()),
+	}
+	unix := f.CreatorVersion>>8 == 3
+	mode := info.Mode()
+	switch {
+	case mode.IsDir():
+		th.Typeflag = tar.TypeDir
+		if !unix {
+			th.Mode = 0755
+		}
+	case mode&os.ModeSymlink != 0:
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		target, err := ioutil.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			return nil, err
+		}
+		th.Typeflag = tar.TypeSymlink
+		th.Linkname = string(target)
+	case mode.IsRegular():
+		th.Typeflag = tar.TypeReg
+		th.Size = int64(f.UncompressedSize64)
+		if !unix {
+			th.Mode = 0644
+		}
+	default:
+		return nil, nil
+	}
+	return th, nil
+}
+
+// LayerFromZip converts a zip archive to a layer, its entries rebased under the Dest option
+func LayerFromZip(zipPath string, options *ImportOptions) (v1.Layer, error) {
+	epoch, err := options.layerOptions().epoch()
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = archive.Close()
+	}()
+	return writeLayer("", options.layerOptions(), func(writer *tar.Writer) error {
+		for _, f := range archive.File {
+			name, err := options.entryName(f.Name)
+			if err != nil {
+				return fmt.Errorf("reading %s: %v", zipPath, err)
+			}
+			if name == "" {
+				continue
+			}
+			th, err := zipHeader(f, name)
+			if err != nil {
+				return fmt.Errorf("reading %s from %s: %v", f.Name, zipPath, err)
+			}
+			if th == nil {
+				log.Warnw("skipping unsupported zip entry", "archive", zipPath, "entry", f.Name)
+				continue
+			}
+			if th.Typeflag == tar.TypeSymlink {
+				err = checkSymlink(f.Name, th.Linkname)
+				if err != nil {
+					return fmt.Errorf("reading %s: %v", zipPath, err)
+				}
+			}
+			err = writer.WriteHeader(normalizeHeader(th, epoch))
+			if err != nil {
+				return err
+			}
+			if th.Typeflag == tar.TypeReg {
+				r, err := f.Open()
+				if err != nil {
+					return err
+				}
+				_, err = io.Copy(writer, r)
+				_ = r.Close()
+				if err != nil {
+					return fmt.Errorf("reading %s from %s: %v", f.Name, zipPath, err)
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,199 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// archiveEntry is an entry of a test archive, content being the symlink target of symlinks
+type archiveEntry struct {
+	name     string
+	typeflag byte
+	content  string
+}
+
+// writeTar writes entries as a gzip compressed tarball in dir
+func writeTar(t *testing.T, dir string, entries []archiveEntry) string {
+	tarPath := filepath.Join(dir, "archive.tar.gz")
+	f, err := os.Create(tarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	for _, entry := range entries {
+		th := &tar.Header{Name: entry.name, Typeflag: entry.typeflag, Mode: 0644}
+		switch entry.typeflag {
+		case tar.TypeReg:
+			th.Size = int64(len(entry.content))
+		case tar.TypeLink, tar.TypeSymlink:
+			th.Linkname = entry.content
+		}
+		err = tw.WriteHeader(th)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.typeflag == tar.TypeReg {
+			_, err = io.WriteString(tw, entry.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	err = tw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tarPath
+}
+
+// writeZip writes regular file entries as a zip archive in dir
+func writeZip(t *testing.T, dir string, files []archiveEntry) string {
+	zipPath := filepath.Join(dir, "archive.zip")
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for _, file := range files {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = io.WriteString(w, file.content)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = zw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zipPath
+}
+
+// layerHeaders returns the entries of layer by name
+func layerHeaders(t *testing.T, layer v1.Layer) map[string]*tar.Header {
+	r, err := layer.Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	res := make(map[string]*tar.Header)
+	tr := tar.NewReader(r)
+	for {
+		th, err := tr.Next()
+		if err == io.EOF {
+			return res
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		res[th.Name] = th
+	}
+}
+
+func TestLayerFromTar(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tarPath := writeTar(t, dir, []archiveEntry{
+		{name: "./", typeflag: tar.TypeDir},
+		{name: "./bin/app", typeflag: tar.TypeReg, content: "app"},
+		{name: "/etc/app.conf", typeflag: tar.TypeReg, content: "conf"},
+		{name: "bin/app-link", typeflag: tar.TypeLink, content: "./bin/app"},
+		{name: "bin/lib", typeflag: tar.TypeSymlink, content: "../lib"},
+		{name: "bin/sh", typeflag: tar.TypeSymlink, content: "/bin/busybox"},
+	})
+	layer, err := LayerFromTar(tarPath, &ImportOptions{Dest: "/opt/app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := layerHeaders(t, layer)
+	for _, name := range []string{"opt/app", "opt/app/bin/app", "opt/app/etc/app.conf"} {
+		if _, ok := headers[name]; !ok {
+			t.Errorf("missing entry %s in %v", name, headers)
+		}
+	}
+	if th := headers["opt/app/bin/app-link"]; th == nil || th.Linkname != "opt/app/bin/app" {
+		t.Errorf("expected the hard link to be rebased, got %+v", th)
+	}
+	if th := headers["opt/app/bin/lib"]; th == nil || th.Linkname != "../lib" {
+		t.Errorf("expected the relative symlink to be kept, got %+v", th)
+	}
+	if th := headers["opt/app/bin/sh"]; th == nil || th.Linkname != "/bin/busybox" {
+		t.Errorf("expected the absolute symlink to be kept, got %+v", th)
+	}
+}
+
+func TestLayerFromTarTraversal(t *testing.T) {
+	for _, entry := range []archiveEntry{
+		{name: "../../etc/passwd", typeflag: tar.TypeReg, content: "root"},
+		{name: "bin/../../etc/passwd", typeflag: tar.TypeReg, content: "root"},
+		{name: "/../etc/passwd", typeflag: tar.TypeReg, content: "root"},
+		{name: "passwd", typeflag: tar.TypeLink, content: "../etc/passwd"},
+		{name: "bin/etc", typeflag: tar.TypeSymlink, content: "../../etc"},
+	} {
+		dir, err := ioutil.TempDir("", "ocilot-archive")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tarPath := writeTar(t, dir, []archiveEntry{entry})
+		if _, err = LayerFromTar(tarPath, &ImportOptions{Dest: "/opt/app"}); err == nil {
+			t.Errorf("expected %s to be rejected", entry.name)
+		}
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestLayerFromZip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	zipPath := writeZip(t, dir, []archiveEntry{{name: "docs/readme.txt", content: "readme"}})
+	layer, err := LayerFromZip(zipPath, &ImportOptions{Dest: "/usr/share"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if th := layerHeaders(t, layer)["usr/share/docs/readme.txt"]; th == nil || th.Size != 6 || th.Mode != 0644 {
+		t.Errorf("expected the zip file below the destination, got %+v", th)
+	}
+	zipPath = writeZip(t, dir, []archiveEntry{{name: "../../etc/passwd", content: "root"}})
+	if _, err = LayerFromZip(zipPath, &ImportOptions{Dest: "/usr/share"}); err == nil {
+		t.Error("expected an entry outside of the archive to be rejected")
+	}
+}
@@ -0,0 +1,161 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"archive/tar"
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	EntryFile    = "file"
+	EntryDir     = "dir"
+	EntrySymlink = "symlink"
+)
+
+// LayerEntry describes a file, directory or symlink to add to a LayerBuilder
+type LayerEntry struct {
+	// Type is one of EntryFile, EntryDir or EntrySymlink, a symlink when Target is set and a file otherwise when empty
+	Type    string
+	Content []byte
+	Target  string
+	// Mode is an octal string, 0644 for files, 0755 for directories and 0777 for symlinks when empty
+	Mode  string
+	Uid   int
+	Gid   int
+	Uname string
+	Gname string
+}
+
+type builderEntry struct {
+	header  *tar.Header
+	content []byte
+}
+
+// LayerBuilder assembles a layer from in-memory entries, without touching the host filesystem
+type LayerBuilder struct {
+	entries map[string]*builderEntry
+}
+
+func NewLayerBuilder() *LayerBuilder {
+	return &LayerBuilder{entries: make(map[string]*builderEntry)}
+}
+
+// entryModTime is the modification time of generated entries, so that the same entries always build the same layer.
+// normalizeHeader never moves it past the layer epoch.
+var entryModTime = time.Unix(0, 0).UTC()
+
+// entryPath returns the tar entry name of an in-image path
+func entryPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// Add records an entry at the in-image path name, replacing any previous entry at the same path
+func (b *LayerBuilder) Add(name string, entry *LayerEntry) error {
+	th := &tar.Header{
+		Name:    entryPath(name),
+		ModTime: entryModTime,
+		Uid:     entry.Uid,
+		Gid:     entry.Gid,
+		Uname:   entry.Uname,
+		Gname:   entry.Gname,
+	}
+	if th.Name == "" {
+		return fmt.Errorf("invalid layer entry path %q", name)
+	}
+	entryType := entry.Type
+	if entryType == "" {
+		entryType = EntryFile
+		if entry.Target != "" {
+			entryType = EntrySymlink
+		}
+	}
+	var content []byte
+	switch entryType {
+	case EntryFile:
+		th.Typeflag = tar.TypeReg
+		th.Mode = 0644
+		th.Size = int64(len(entry.Content))
+		content = entry.Content
+	case EntryDir:
+		th.Typeflag = tar.TypeDir
+		th.Mode = 0755
+	case EntrySymlink:
+		if entry.Target == "" {
+			return fmt.Errorf("symlink %s needs a target", name)
+		}
+		th.Typeflag = tar.TypeSymlink
+		th.Mode = 0777
+		th.Linkname = entry.Target
+	default:
+		return fmt.Errorf("unknown type %q for %s, expected %s, %s or %s", entryType, name, EntryFile, EntryDir, EntrySymlink)
+	}
+	if entry.Mode != "" {
+		mode, err := parseMode(entry.Mode)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		th.Mode = mode
+	}
+	b.AddHeader(th, content)
+	return nil
+}
+
+// AddHeader records a raw tar entry, content being the data of regular files
+func (b *LayerBuilder) AddHeader(th *tar.Header, content []byte) {
+	b.entries[th.Name] = &builderEntry{header: th, content: content}
+}
+
+// Len returns the number of entries recorded
+func (b *LayerBuilder) Len() int {
+	return len(b.entries)
+}
+
+// Build writes the recorded entries as a layer, in name order, workFile is optional
+func (b *LayerBuilder) Build(workFile string, options *LayerOptions) (v1.Layer, error) {
+	epoch, err := options.epoch()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(b.entries))
+	for name := range b.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return writeLayer(workFile, options, func(writer *tar.Writer) error {
+		for _, name := range names {
+			entry := b.entries[name]
+			err := writer.WriteHeader(normalizeHeader(entry.header, epoch))
+			if err != nil {
+				return err
+			}
+			if entry.header.Typeflag == tar.TypeReg {
+				_, err = writer.Write(entry.content)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,39 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLayerBuilderReproducible(t *testing.T) {
+	setEpoch(t, "")
+	first, err := buildLayer(t, "/etc/motd", "hello", nil).Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+	second, err := buildLayer(t, "/etc/motd", "hello", nil).Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatalf("expected the same layer, got %s and %s", first, second)
+	}
+}
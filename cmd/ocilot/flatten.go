@@ -0,0 +1,74 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"ocilot"
+)
+
+var flattenCmd = &cobra.Command{
+	Use:     "flatten <image>",
+	Short:   "Squashes all the layers of an image into a single one and pushes it",
+	Example: "ocilot flatten registry.example.com/app:1.0 -t oci://app-flat",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer cleanup()
+		target, err := cmd.Flags().GetString("target")
+		if err != nil {
+			return err
+		}
+		if target == "" {
+			multiPlatform, err := ocilot.IsIndex(args[0])
+			if err != nil {
+				return err
+			}
+			if multiPlatform {
+				return fmt.Errorf("%s is a multi-platform image, pushing a single platform back would replace it, set a target with -t", args[0])
+			}
+			target = args[0]
+		}
+		image, err := ocilot.LoadImage(args[0])
+		if err != nil {
+			log.With("image", args[0], "error", err).Error("loading image")
+			return err
+		}
+		err = image.Squash(nil)
+		if err != nil {
+			log.With("image", args[0], "error", err).Error("flattening image")
+			return err
+		}
+		image, err = image.Clone(target)
+		if err != nil {
+			return err
+		}
+		err = image.Push()
+		if err != nil {
+			log.With("image", target, "error", err).Error("pushing image")
+			return err
+		}
+		log.With("image", target).Info("Image flattened")
+		return nil
+	},
+}
+
+func init() {
+	flattenCmd.Flags().StringP("target", "t", "", "name of the flattened image, the image itself by default, required for multi-platform images")
+	rootCmd.AddCommand(flattenCmd)
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"ocilot"
 	script "ocilot/script_interface"
 	"os"
 )
@@ -67,9 +68,24 @@ var rootCmd = &cobra.Command{
 			}
 			log = logger.Sugar()
 		}
+		compression, err := cmd.Flags().GetString("compression")
+		if err != nil {
+			panic(err)
+		}
+		ocilot.DefaultCompression = compression
+		if cmd.Flags().Changed("compression-level") {
+			ocilot.DefaultCompressionLevel, err = cmd.Flags().GetInt("compression-level")
+			if err != nil {
+				panic(err)
+			}
+		} else if compression != ocilot.CompressionGzip {
+			ocilot.DefaultCompressionLevel = 0
+		}
+		ocilot.SetLogger(log)
 		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
+		defer cleanup()
 		var remaining []string
 		if len(args) > 1 {
 			remaining = args[1:]
@@ -120,8 +136,18 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+// cleanup removes the work directory of the run
+func cleanup() {
+	err := ocilot.Cleanup()
+	if err != nil {
+		log.With("error", err).Warn("removing work directory")
+	}
+}
+
 func init() {
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Verbose Logging")
 	rootCmd.PersistentFlags().BoolP("log-json", "j", false, "JSON logging output")
 	rootCmd.PersistentFlags().StringP("lib", "l", "/ocilot", "lua libraries folder")
+	rootCmd.PersistentFlags().String("compression", ocilot.CompressionGzip, "default layer compression: gzip, zstd or none")
+	rootCmd.PersistentFlags().Int("compression-level", ocilot.DefaultCompressionLevel, "default layer compression level")
 }
@@ -0,0 +1,100 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"ocilot"
+)
+
+var rebaseCmd = &cobra.Command{
+	Use:     "rebase <image> <old-base> <new-base>",
+	Short:   "Moves the layers of an image from its old base to a new one and pushes it, without rebuilding them",
+	Example: "ocilot rebase registry.example.com/app:1.0 python:3.8.2-slim python:3.8.3-slim",
+	Args:    cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer cleanup()
+		target, err := cmd.Flags().GetString("target")
+		if err != nil {
+			return err
+		}
+		if target == "" {
+			multiPlatform, err := ocilot.IsIndex(args[0])
+			if err != nil {
+				return err
+			}
+			if multiPlatform {
+				return fmt.Errorf("%s is a multi-platform image, pushing a single platform back would replace it, set a target with -t", args[0])
+			}
+			target = args[0]
+		}
+		options := &ocilot.RebaseOptions{}
+		for flag, rule := range map[string]*string{
+			"env":        &options.Env,
+			"labels":     &options.Labels,
+			"entrypoint": &options.Entrypoint,
+			"cmd":        &options.Cmd,
+			"workdir":    &options.WorkingDir,
+			"user":       &options.User,
+		} {
+			value, err := cmd.Flags().GetString(flag)
+			if err != nil {
+				return err
+			}
+			*rule = value
+		}
+		images := make([]*ocilot.Image, 0, len(args))
+		for _, imageName := range args {
+			image, err := ocilot.LoadImage(imageName)
+			if err != nil {
+				log.With("image", imageName, "error", err).Error("loading image")
+				return err
+			}
+			images = append(images, image)
+		}
+		image := images[0]
+		err = image.Rebase(images[1], images[2], options)
+		if err != nil {
+			log.With("image", args[0], "error", err).Error("rebasing image")
+			return err
+		}
+		image, err = image.Clone(target)
+		if err != nil {
+			return err
+		}
+		err = image.Push()
+		if err != nil {
+			log.With("image", target, "error", err).Error("pushing image")
+			return err
+		}
+		log.With("image", target, "base", args[2]).Info("Image rebased")
+		return nil
+	},
+}
+
+func init() {
+	rebaseCmd.Flags().StringP("target", "t", "", "name of the rebased image, the image itself by default, required for multi-platform images")
+	rebaseCmd.Flags().String("env", ocilot.RebaseMerge, "env rule: merge, image or base")
+	rebaseCmd.Flags().String("labels", ocilot.RebaseMerge, "labels rule: merge, image or base")
+	rebaseCmd.Flags().String("entrypoint", ocilot.RebaseMerge, "entrypoint rule: merge, image or base")
+	rebaseCmd.Flags().String("cmd", ocilot.RebaseMerge, "cmd rule: merge, image or base")
+	rebaseCmd.Flags().String("workdir", ocilot.RebaseMerge, "working directory rule: merge, image or base")
+	rebaseCmd.Flags().String("user", ocilot.RebaseMerge, "user rule: merge, image or base")
+	rootCmd.AddCommand(rebaseCmd)
+}
@@ -0,0 +1,49 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"ocilot"
+)
+
+var unpackCmd = &cobra.Command{
+	Use:     "unpack <image> <dir>",
+	Short:   "Unpacks the filesystem of a remote, docker:// or oci:// image to a local directory",
+	Example: "ocilot unpack alpine:3.11 rootfs",
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer cleanup()
+		image, err := ocilot.LoadImage(args[0])
+		if err != nil {
+			log.With("image", args[0], "error", err).Error("loading image")
+			return err
+		}
+		err = image.Unpack(args[1])
+		if err != nil {
+			log.With("image", args[0], "error", err).Error("unpacking image")
+			return err
+		}
+		log.With("image", image.String(), "dir", args[1]).Info("Image unpacked")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(unpackCmd)
+}
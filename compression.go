@@ -0,0 +1,146 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"io"
+)
+
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+	CompressionNone = "none"
+)
+
+// DefaultCompression and DefaultCompressionLevel apply to layers built without explicit compression settings
+var (
+	DefaultCompression      = CompressionGzip
+	DefaultCompressionLevel = 2
+)
+
+// compression returns the algorithm and level to use, falling back to the run defaults
+func (o *LayerOptions) compression() (string, int, error) {
+	compression := DefaultCompression
+	if o != nil && o.Compression != "" {
+		compression = o.Compression
+	}
+	var level int
+	switch {
+	case o != nil && o.Level != nil:
+		level = *o.Level
+	case compression == DefaultCompression:
+		level = DefaultCompressionLevel
+	case compression == CompressionGzip:
+		level = gzip.DefaultCompression
+	}
+	switch compression {
+	case CompressionGzip:
+		if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+			return "", 0, fmt.Errorf("invalid gzip level %d", level)
+		}
+	case CompressionZstd:
+		if level < 0 || level > 22 {
+			return "", 0, fmt.Errorf("invalid zstd level %d", level)
+		}
+	case CompressionNone:
+	default:
+		return "", 0, fmt.Errorf("unknown compression %q, expected %s, %s or %s", compression, CompressionGzip, CompressionZstd, CompressionNone)
+	}
+	return compression, level, nil
+}
+
+// newCompressor wraps w with the given compression, a zstd level of 0 uses the library default.
+// When parallel is set gzip blocks are compressed concurrently, zstd always is.
+func newCompressor(w io.Writer, compression string, level int, parallel bool) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionZstd:
+		if level == 0 {
+			return zstd.NewWriter(w)
+		}
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	default:
+		if parallel {
+			return pgzip.NewWriterLevel(w, level)
+		}
+		return gzip.NewWriterLevel(w, level)
+	}
+}
+
+type decompressor struct {
+	io.Reader
+	closers []func() error
+}
+
+func (d *decompressor) Close() error {
+	var res error
+	for _, closer := range d.closers {
+		if err := closer(); err != nil && res == nil {
+			res = err
+		}
+	}
+	return res
+}
+
+// newDecompressor reads the uncompressed content of r, closing r when done
+func newDecompressor(r io.ReadCloser, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case CompressionNone:
+		return r, nil
+	case CompressionZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+		return &decompressor{Reader: zr, closers: []func() error{func() error { zr.Close(); return nil }, r.Close}}, nil
+	default:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+		return &decompressor{Reader: gr, closers: []func() error{gr.Close, r.Close}}, nil
+	}
+}
+
+func compressedExtension(compression string) string {
+	switch compression {
+	case CompressionZstd:
+		return ".zst"
+	default:
+		return ".gz"
+	}
+}
+
+// layerMediaType returns the media type of layers built with the given compression,
+// zstd and uncompressed layers only having an OCI one
+func layerMediaType(compression string) types.MediaType {
+	switch compression {
+	case CompressionZstd:
+		return types.OCILayerZStd
+	case CompressionNone:
+		return types.OCIUncompressedLayer
+	default:
+		return types.DockerLayer
+	}
+}
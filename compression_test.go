@@ -0,0 +1,98 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// buildLayer returns a layer holding a single file
+func buildLayer(t *testing.T, name string, content string, options *LayerOptions) v1.Layer {
+	builder := NewLayerBuilder()
+	err := builder.Add(name, &LayerEntry{Content: []byte(content)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer, err := builder.Build("", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return layer
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-compression")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	image, err := NewScratchImage(v1.Platform{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"/gzip.txt": CompressionGzip,
+		"/zstd.txt": CompressionZstd,
+		"/none.txt": CompressionNone,
+	}
+	for _, name := range []string{"/gzip.txt", "/zstd.txt", "/none.txt"} {
+		err = image.AddLayer(buildLayer(t, name, files[name], &LayerOptions{Compression: files[name]}))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	target, err := image.Clone(LayoutPrefix + dir + ":test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = target.Push()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pulled, err := LoadImage(LayoutPrefix + dir + ":test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		_, data, err := pulled.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("%s: expected %q, got %q", name, content, data)
+		}
+	}
+	// A Docker manifest cannot describe zstd layers, the whole image switches to OCI media types
+	manifest, err := pulled.img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.MediaType != types.OCIManifestSchema1 || manifest.Config.MediaType != types.OCIConfigJSON {
+		t.Errorf("expected an OCI manifest and config, got %s and %s", manifest.MediaType, manifest.Config.MediaType)
+	}
+	expected := []types.MediaType{types.OCILayer, types.OCILayerZStd, types.OCIUncompressedLayer}
+	for n, layer := range manifest.Layers {
+		if layer.MediaType != expected[n] {
+			t.Errorf("layer %d: expected %s, got %s", n, expected[n], layer.MediaType)
+		}
+	}
+}
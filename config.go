@@ -0,0 +1,257 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseConfig decodes a JSON image config, rejecting unknown keys
+func ParseConfig(data []byte) (*v1.Config, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	config := &v1.Config{}
+	err := decoder.Decode(config)
+	if err != nil {
+		message := strings.TrimPrefix(err.Error(), "json: ")
+		return nil, fmt.Errorf("invalid config: %s", strings.Replace(message, "unknown field", "unknown key", 1))
+	}
+	return config, nil
+}
+
+// updateConfig applies update to a copy of the image config, then sets it
+func (i *Image) updateConfig(update func(config *v1.Config) error) error {
+	config, err := i.GetConfig()
+	if err != nil {
+		return err
+	}
+	err = update(config)
+	if err != nil {
+		return err
+	}
+	return i.WithConfig(config)
+}
+
+// SetEnv sets the environment variable key, replacing its previous value
+func (i *Image) SetEnv(key string, value string) error {
+	if key == "" || strings.Contains(key, "=") {
+		return fmt.Errorf("invalid environment variable name %q", key)
+	}
+	return i.updateConfig(func(config *v1.Config) error {
+		for n, variable := range config.Env {
+			if strings.SplitN(variable, "=", 2)[0] == key {
+				config.Env[n] = key + "=" + value
+				return nil
+			}
+		}
+		config.Env = append(config.Env, key+"="+value)
+		return nil
+	})
+}
+
+// UnsetEnv removes the environment variable key
+func (i *Image) UnsetEnv(key string) error {
+	return i.updateConfig(func(config *v1.Config) error {
+		env := make([]string, 0, len(config.Env))
+		for _, variable := range config.Env {
+			if strings.SplitN(variable, "=", 2)[0] != key {
+				env = append(env, variable)
+			}
+		}
+		config.Env = env
+		return nil
+	})
+}
+
+// SetEntrypoint sets the entrypoint in exec form, clearing it when empty
+func (i *Image) SetEntrypoint(entrypoint []string) error {
+	return i.updateConfig(func(config *v1.Config) error {
+		config.Entrypoint = entrypoint
+		return nil
+	})
+}
+
+// SetCmd sets the command in exec form, clearing it when empty
+func (i *Image) SetCmd(cmd []string) error {
+	return i.updateConfig(func(config *v1.Config) error {
+		config.Cmd = cmd
+		return nil
+	})
+}
+
+// SetWorkdir sets the working directory, which must be absolute
+func (i *Image) SetWorkdir(dir string) error {
+	if !path.IsAbs(dir) {
+		return fmt.Errorf("working directory %q must be absolute", dir)
+	}
+	return i.updateConfig(func(config *v1.Config) error {
+		config.WorkingDir = path.Clean(dir)
+		return nil
+	})
+}
+
+var userPattern = regexp.MustCompile(`^[^:\s]+(:[^:\s]+)?$`)
+
+// SetUser sets the user as user, uid, user:group or uid:gid
+func (i *Image) SetUser(user string) error {
+	if !userPattern.MatchString(user) {
+		return fmt.Errorf("invalid user %q, expected user[:group]", user)
+	}
+	return i.updateConfig(func(config *v1.Config) error {
+		config.User = user
+		return nil
+	})
+}
+
+// ExposePort declares a port as port[/protocol], tcp being the default protocol
+func (i *Image) ExposePort(port string) error {
+	number, protocol := port, "tcp"
+	if sep := strings.Index(port, "/"); sep >= 0 {
+		number, protocol = port[:sep], strings.ToLower(port[sep+1:])
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	if protocol != "tcp" && protocol != "udp" && protocol != "sctp" {
+		return fmt.Errorf("invalid protocol %q for port %s, expected tcp, udp or sctp", protocol, number)
+	}
+	return i.updateConfig(func(config *v1.Config) error {
+		if config.ExposedPorts == nil {
+			config.ExposedPorts = make(map[string]struct{})
+		}
+		config.ExposedPorts[strconv.Itoa(n)+"/"+protocol] = struct{}{}
+		return nil
+	})
+}
+
+// AddVolume declares an absolute path as a volume
+func (i *Image) AddVolume(volume string) error {
+	if !path.IsAbs(volume) {
+		return fmt.Errorf("volume %q must be absolute", volume)
+	}
+	return i.updateConfig(func(config *v1.Config) error {
+		if config.Volumes == nil {
+			config.Volumes = make(map[string]struct{})
+		}
+		config.Volumes[path.Clean(volume)] = struct{}{}
+		return nil
+	})
+}
+
+// SetLabel sets the label key
+func (i *Image) SetLabel(key string, value string) error {
+	if key == "" {
+		return fmt.Errorf("empty label name")
+	}
+	return i.updateConfig(func(config *v1.Config) error {
+		if config.Labels == nil {
+			config.Labels = make(map[string]string)
+		}
+		config.Labels[key] = value
+		return nil
+	})
+}
+
+var signalPattern = regexp.MustCompile(`^(SIG)?[A-Z][A-Z0-9]*([+-][0-9]+)?$`)
+
+// SetStopSignal sets the signal stopping containers, as a name such as SIGTERM or a number
+func (i *Image) SetStopSignal(signal string) error {
+	if n, err := strconv.Atoi(signal); err == nil {
+		if n < 1 || n > 64 {
+			return fmt.Errorf("invalid stop signal %d", n)
+		}
+	} else if signalPattern.MatchString(strings.ToUpper(signal)) {
+		signal = strings.ToUpper(signal)
+		if !strings.HasPrefix(signal, "SIG") {
+			signal = "SIG" + signal
+		}
+	} else {
+		return fmt.Errorf("invalid stop signal %q", signal)
+	}
+	return i.updateConfig(func(config *v1.Config) error {
+		config.StopSignal = signal
+		return nil
+	})
+}
+
+// Healthcheck describes the check of a container health, durations being strings such as "30s"
+type Healthcheck struct {
+	// Test is either ["NONE"], ["CMD", args...] or ["CMD-SHELL", command]
+	Test        []string `json:"test"`
+	Interval    string   `json:"interval"`
+	Timeout     string   `json:"timeout"`
+	StartPeriod string   `json:"startPeriod"`
+	Retries     int      `json:"retries"`
+}
+
+// SetHealthcheck sets the health check of containers
+func (i *Image) SetHealthcheck(healthcheck *Healthcheck) error {
+	if len(healthcheck.Test) == 0 {
+		return fmt.Errorf("a healthcheck needs a test")
+	}
+	switch healthcheck.Test[0] {
+	case "NONE":
+		if len(healthcheck.Test) != 1 {
+			return fmt.Errorf("a NONE healthcheck takes no argument")
+		}
+	case "CMD", "CMD-SHELL":
+		if len(healthcheck.Test) < 2 {
+			return fmt.Errorf("a %s healthcheck needs a command", healthcheck.Test[0])
+		}
+	default:
+		return fmt.Errorf("invalid healthcheck test %q, expected NONE, CMD or CMD-SHELL", healthcheck.Test[0])
+	}
+	if healthcheck.Retries < 0 {
+		return fmt.Errorf("invalid healthcheck retries %d", healthcheck.Retries)
+	}
+	res := &v1.HealthConfig{
+		Test:    healthcheck.Test,
+		Retries: healthcheck.Retries,
+	}
+	for _, duration := range []struct {
+		name   string
+		value  string
+		target *time.Duration
+	}{
+		{"interval", healthcheck.Interval, &res.Interval},
+		{"timeout", healthcheck.Timeout, &res.Timeout},
+		{"startPeriod", healthcheck.StartPeriod, &res.StartPeriod},
+	} {
+		if duration.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(duration.value)
+		if err != nil || parsed < 0 {
+			return fmt.Errorf("invalid healthcheck %s %q", duration.name, duration.value)
+		}
+		*duration.target = parsed
+	}
+	return i.updateConfig(func(config *v1.Config) error {
+		config.Healthcheck = res
+		return nil
+	})
+}
@@ -0,0 +1,83 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConfigUnknownKey(t *testing.T) {
+	config, err := ParseConfig([]byte(`{"Env":["A=1"],"User":"app"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.User != "app" || len(config.Env) != 1 {
+		t.Fatalf("unexpected config %+v", config)
+	}
+	_, err = ParseConfig([]byte(`{"Usr":"app"}`))
+	if err == nil || !strings.Contains(err.Error(), `unknown key "Usr"`) {
+		t.Fatalf("expected an unknown key error, got %v", err)
+	}
+}
+
+func TestConfigSetters(t *testing.T) {
+	image := layersImage(t)
+	for _, err := range []error{
+		image.SetEnv("PATH", "/bin"),
+		image.SetEnv("PATH", "/usr/bin"),
+		image.SetUser("app:app"),
+		image.ExposePort("8080/UDP"),
+		image.SetLabel("version", "1"),
+		image.SetStopSignal("term"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	config, err := image.GetConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Env) != 1 || config.Env[0] != "PATH=/usr/bin" {
+		t.Errorf("unexpected env %v", config.Env)
+	}
+	if config.User != "app:app" {
+		t.Errorf("unexpected user %q", config.User)
+	}
+	if _, ok := config.ExposedPorts["8080/udp"]; !ok {
+		t.Errorf("unexpected exposed ports %v", config.ExposedPorts)
+	}
+	if config.Labels["version"] != "1" {
+		t.Errorf("unexpected labels %v", config.Labels)
+	}
+	if config.StopSignal != "SIGTERM" {
+		t.Errorf("unexpected stop signal %q", config.StopSignal)
+	}
+	for name, err := range map[string]error{
+		"env":    image.SetEnv("A=B", "1"),
+		"user":   image.SetUser("app:"),
+		"port":   image.ExposePort("70000"),
+		"label":  image.SetLabel("", "1"),
+		"signal": image.SetStopSignal("99"),
+	} {
+		if err == nil {
+			t.Errorf("expected an invalid %s error", name)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"archive/tar"
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// CopyOptions controls the ownership and permissions of files copied from another image
+type CopyOptions struct {
+	Uid *int `json:"uid"`
+	Gid *int `json:"gid"`
+	// Mode is an octal string applied to copied files
+	Mode string `json:"mode"`
+	// DirMode is an octal string applied to copied directories
+	DirMode string `json:"dirMode"`
+	LayerOptions
+}
+
+func (o *CopyOptions) layerOptions() *LayerOptions {
+	if o == nil {
+		return nil
+	}
+	return &o.LayerOptions
+}
+
+// apply rewrites the ownership and permissions of a copied entry
+func (o *CopyOptions) apply(th *tar.Header) error {
+	if o == nil {
+		return nil
+	}
+	if o.Uid != nil {
+		th.Uid = *o.Uid
+		th.Uname = ""
+	}
+	if o.Gid != nil {
+		th.Gid = *o.Gid
+		th.Gname = ""
+	}
+	mode := ""
+	switch th.Typeflag {
+	case tar.TypeReg:
+		mode = o.Mode
+	case tar.TypeDir:
+		mode = o.DirMode
+	}
+	if mode != "" {
+		parsed, err := parseMode(mode)
+		if err != nil {
+			return err
+		}
+		th.Mode = parsed
+	}
+	return nil
+}
+
+// resolveSource follows the symlinks of a copied path within files
+func resolveSource(files map[string]*fsEntry, name string) (string, error) {
+	for depth := 0; depth < maxLinkDepth; depth++ {
+		entry, ok := files[name]
+		if !ok || entry.header.Typeflag != tar.TypeSymlink {
+			return name, nil
+		}
+		target := entry.header.Linkname
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir("/"+name), target)
+		}
+		name = entryPath(target)
+	}
+	return "", fmt.Errorf("%s: too many levels of links", name)
+}
+
+// contentSpool keeps the contents of files read from layers in a work file, by layer index and entry name,
+// so that each layer is only read once
+type contentSpool struct {
+	file    *os.File
+	size    int64
+	entries map[spoolKey]spoolEntry
+}
+
+type spoolKey struct {
+	layer int
+	name  string
+}
+
+type spoolEntry struct {
+	offset int64
+	size   int64
+}
+
+func newContentSpool() (*contentSpool, error) {
+	workFile, err := newWorkFile("copy-*.spool")
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(workFile, os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &contentSpool{file: f, entries: make(map[spoolKey]spoolEntry)}, nil
+}
+
+func (s *contentSpool) add(layer int, name string, r io.Reader) error {
+	n, err := io.Copy(s.file, r)
+	if err != nil {
+		return err
+	}
+	s.entries[spoolKey{layer: layer, name: name}] = spoolEntry{offset: s.size, size: n}
+	s.size += n
+	return nil
+}
+
+func (s *contentSpool) has(layer int, name string) bool {
+	_, ok := s.entries[spoolKey{layer: layer, name: name}]
+	return ok
+}
+
+func (s *contentSpool) reader(layer int, name string) (*io.SectionReader, error) {
+	entry, ok := s.entries[spoolKey{layer: layer, name: name}]
+	if !ok {
+		return nil, fmt.Errorf("content of %s from layer %d was not read", name, layer)
+	}
+	return io.NewSectionReader(s.file, entry.offset, entry.size), nil
+}
+
+func (s *contentSpool) Close() error {
+	err := s.file.Close()
+	if removeErr := os.Remove(s.file.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// CopyFrom appends a layer holding src copied from the filesystem of other to dest.
+// A directory has its content copied to dest, a file is copied to dest, or inside it when dest ends with "/".
+// Layers of other are read once, keeping the contents found below src aside. Only the contents this pass could not
+// anticipate, behind a symlinked src or hardlinks to files outside of it, need their layer to be read again.
+func (i *Image) CopyFrom(other *Image, src string, dest string, options *CopyOptions) error {
+	layers, err := other.img.Layers()
+	if err != nil {
+		return err
+	}
+	spool, err := newContentSpool()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = spool.Close()
+	}()
+	literal := entryPath(src)
+	files := make(map[string]*fsEntry)
+	for index, layer := range layers {
+		entries := make([]*tar.Header, 0)
+		err := readLayer(layer, func(th *tar.Header, r io.Reader) error {
+			entries = append(entries, th)
+			if th.Typeflag == tar.TypeReg && isBelow(th.Name, literal) {
+				return spool.add(index, th.Name, r)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("reading layer %d of %s: %v", index, other, err)
+		}
+		applyLayer(files, index, entries)
+	}
+	source, err := resolveSource(files, literal)
+	if err != nil {
+		return fmt.Errorf("%v in %s", err, other)
+	}
+	entry, ok := files[source]
+	isDir := !ok || entry.header.Typeflag == tar.TypeDir
+	selected := make(map[string]*tar.Header)
+	for name, e := range files {
+		if name == source || (isDir && isBelow(name, source)) {
+			selected[name] = e.header
+		}
+	}
+	if len(selected) == 0 {
+		return fmt.Errorf("%s: no such file in %s", src, other)
+	}
+	rename := func(name string) string {
+		if !isDir {
+			if strings.HasSuffix(dest, "/") {
+				return entryPath(path.Join(dest, path.Base(name)))
+			}
+			return entryPath(dest)
+		}
+		return entryPath(path.Join(dest, strings.TrimPrefix(name, source)))
+	}
+	// Contents are all gathered before writing. Hardlinks to files outside of the copy become regular files
+	// holding the content their target has in the layer of the link.
+	missing := make(map[int]map[string]bool)
+	want := func(layer int, name string) {
+		if !spool.has(layer, name) {
+			if missing[layer] == nil {
+				missing[layer] = make(map[string]bool)
+			}
+			missing[layer][name] = true
+		}
+	}
+	for name, th := range selected {
+		switch th.Typeflag {
+		case tar.TypeReg:
+			want(files[name].layer, name)
+		case tar.TypeLink:
+			if _, ok := selected[th.Linkname]; !ok {
+				want(files[name].layer, th.Linkname)
+			}
+		}
+	}
+	for index, names := range missing {
+		err := readLayer(layers[index], func(th *tar.Header, r io.Reader) error {
+			if names[th.Name] && th.Typeflag == tar.TypeReg && !spool.has(index, th.Name) {
+				return spool.add(index, th.Name, r)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("reading layer %d of %s: %v", index, other, err)
+		}
+		for name := range names {
+			if !spool.has(index, name) {
+				return fmt.Errorf("%s: no file content in layer %d of %s", name, index, other)
+			}
+		}
+	}
+	epoch, err := options.layerOptions().epoch()
+	if err != nil {
+		return err
+	}
+	layer, err := writeLayer("", options.layerOptions(), func(writer *tar.Writer) error {
+		// Hardlinks are written last so that their targets always come first
+		names := sortedPaths(selected)
+		sort.SliceStable(names, func(a, b int) bool {
+			return selected[names[a]].Typeflag != tar.TypeLink && selected[names[b]].Typeflag == tar.TypeLink
+		})
+		for _, name := range names {
+			header := *selected[name]
+			header.Name = rename(name)
+			if header.Name == "" {
+				continue
+			}
+			var content io.Reader
+			switch header.Typeflag {
+			case tar.TypeReg:
+				r, err := spool.reader(files[name].layer, name)
+				if err != nil {
+					return err
+				}
+				header.Size = r.Size()
+				content = r
+			case tar.TypeLink:
+				if _, ok := selected[header.Linkname]; ok {
+					header.Linkname = rename(header.Linkname)
+					break
+				}
+				r, err := spool.reader(files[name].layer, header.Linkname)
+				if err != nil {
+					return err
+				}
+				header.Typeflag = tar.TypeReg
+				header.Linkname = ""
+				header.Size = r.Size()
+				content = r
+			}
+			err := options.apply(&header)
+			if err != nil {
+				return err
+			}
+			err = writer.WriteHeader(normalizeHeader(&header, epoch))
+			if err != nil {
+				return err
+			}
+			if content != nil {
+				_, err = io.Copy(writer, content)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return i.AppendLayer(layer, v1.History{CreatedBy: fmt.Sprintf("ocilot copyFrom %s %s %s", other, src, dest)})
+}
@@ -0,0 +1,151 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"archive/tar"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"io"
+	"testing"
+)
+
+// countingLayer counts how many times its content is read
+type countingLayer struct {
+	v1.Layer
+	reads int
+}
+
+func (l *countingLayer) Uncompressed() (io.ReadCloser, error) {
+	l.reads++
+	return l.Layer.Uncompressed()
+}
+
+// headerLayer builds a layer from raw headers and the contents of their regular files
+func headerLayer(t *testing.T, headers []*tar.Header, contents map[string]string) *countingLayer {
+	builder := NewLayerBuilder()
+	for _, th := range headers {
+		content := []byte(contents[th.Name])
+		if th.Typeflag == tar.TypeReg {
+			th.Size = int64(len(content))
+		}
+		if th.Mode == 0 {
+			th.Mode = 0644
+		}
+		builder.AddHeader(th, content)
+	}
+	layer, err := builder.Build("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &countingLayer{Layer: layer}
+}
+
+func layersImage(t *testing.T, layers ...v1.Layer) *Image {
+	image, err := NewScratchImage(v1.Platform{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, layer := range layers {
+		err = image.AddLayer(layer)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return image
+}
+
+func readContent(t *testing.T, image *Image, name string) string {
+	_, content, err := image.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestCopyFromReadsLayersOnce(t *testing.T) {
+	lower := headerLayer(t, []*tar.Header{
+		{Name: "app", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "app/a", Typeflag: tar.TypeReg},
+		{Name: "app/b", Typeflag: tar.TypeReg},
+	}, map[string]string{"app/a": "a", "app/b": "b"})
+	upper := headerLayer(t, []*tar.Header{
+		{Name: "app/b", Typeflag: tar.TypeReg},
+		{Name: "other", Typeflag: tar.TypeReg},
+	}, map[string]string{"app/b": "b2", "other": "o"})
+	other := layersImage(t, lower, upper)
+	image := layersImage(t)
+	err := image.CopyFrom(other, "/app", "/dst", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lower.reads != 1 || upper.reads != 1 {
+		t.Errorf("expected each layer to be read once, got %d and %d", lower.reads, upper.reads)
+	}
+	if content := readContent(t, image, "/dst/a"); content != "a" {
+		t.Errorf("expected a, got %q", content)
+	}
+	if content := readContent(t, image, "/dst/b"); content != "b2" {
+		t.Errorf("expected b2, got %q", content)
+	}
+	if _, _, err := image.ReadFile("/dst/other"); err == nil {
+		t.Error("expected other not to be copied")
+	}
+}
+
+func TestCopyFromHardlinkOutside(t *testing.T) {
+	lower := headerLayer(t, []*tar.Header{
+		{Name: "data", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "data/target", Typeflag: tar.TypeReg},
+		{Name: "app", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "app/link", Typeflag: tar.TypeLink, Linkname: "data/target"},
+	}, map[string]string{"data/target": "old"})
+	// The target is replaced above the link, which keeps the content of its own layer
+	upper := headerLayer(t, []*tar.Header{
+		{Name: "data/target", Typeflag: tar.TypeReg},
+	}, map[string]string{"data/target": "new"})
+	other := layersImage(t, lower, upper)
+	image := layersImage(t)
+	err := image.CopyFrom(other, "/app", "/dst", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	th, content, err := image.ReadFile("/dst/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if th.Typeflag != tar.TypeReg || string(content) != "old" {
+		t.Errorf("expected a regular file holding old, got type %c holding %q", th.Typeflag, content)
+	}
+}
+
+func TestCopyFromHardlinkInside(t *testing.T) {
+	lower := headerLayer(t, []*tar.Header{
+		{Name: "app", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "app/a", Typeflag: tar.TypeReg},
+		{Name: "app/b", Typeflag: tar.TypeLink, Linkname: "app/a"},
+	}, map[string]string{"app/a": "a"})
+	other := layersImage(t, lower)
+	image := layersImage(t)
+	err := image.CopyFrom(other, "/app", "/dst", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content := readContent(t, image, "/dst/b"); content != "a" {
+		t.Errorf("expected a, got %q", content)
+	}
+}
@@ -0,0 +1,340 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"archive/tar"
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+)
+
+// fsEntry is a file of the merged filesystem of an image, along with the index of the layer providing it
+type fsEntry struct {
+	header *tar.Header
+	layer  int
+}
+
+// readLayer calls fn on every entry of the layer, r reading the entry content
+func readLayer(layer v1.Layer, fn func(th *tar.Header, r io.Reader) error) error {
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	reader := tar.NewReader(rc)
+	for {
+		th, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		th.Name = entryPath(th.Name)
+		if th.Typeflag == tar.TypeLink {
+			th.Linkname = entryPath(th.Linkname)
+		}
+		err = fn(th, reader)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// isBelow reports whether name is dir itself or inside it, dir being "" for the root
+func isBelow(name string, dir string) bool {
+	return dir == "" || name == dir || strings.HasPrefix(name, dir+"/")
+}
+
+// removeTree deletes name and everything below it from files
+func removeTree(files map[string]*fsEntry, name string) {
+	for other := range files {
+		if isBelow(other, name) {
+			delete(files, other)
+		}
+	}
+}
+
+// applyLayer merges the entries of a layer into files, resolving its whiteouts against the lower layers
+func applyLayer(files map[string]*fsEntry, index int, entries []*tar.Header) {
+	for _, th := range entries {
+		base := path.Base(th.Name)
+		dir := path.Dir(th.Name)
+		if dir == "." {
+			dir = ""
+		}
+		switch {
+		case base == OpaqueWhiteout:
+			for other, entry := range files {
+				if other != dir && isBelow(other, dir) && entry.layer < index {
+					delete(files, other)
+				}
+			}
+		case strings.HasPrefix(base, WhiteoutPrefix):
+			removeTree(files, path.Join(dir, strings.TrimPrefix(base, WhiteoutPrefix)))
+		}
+	}
+	for _, th := range entries {
+		if IsWhiteout(th.Name) {
+			continue
+		}
+		if previous, ok := files[th.Name]; ok && previous.header.Typeflag == tar.TypeDir && th.Typeflag != tar.TypeDir {
+			removeTree(files, th.Name)
+		}
+		files[th.Name] = &fsEntry{header: th, layer: index}
+	}
+}
+
+// mergedFiles returns the filesystem resulting of the application of all layers, by entry name
+func mergedFiles(layers []v1.Layer) (map[string]*fsEntry, error) {
+	files := make(map[string]*fsEntry)
+	for index, layer := range layers {
+		entries := make([]*tar.Header, 0)
+		err := readLayer(layer, func(th *tar.Header, r io.Reader) error {
+			entries = append(entries, th)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("reading layer %d: %v", index, err)
+		}
+		applyLayer(files, index, entries)
+	}
+	return files, nil
+}
+
+// matchFiles returns the names of files matching an absolute path pattern, "*" and "**" globs being supported.
+// Directories only implied by the path of their children also match, entries below a matching directory are not returned.
+func matchFiles(files map[string]*fsEntry, pattern string) []string {
+	p, ok := parsePattern("/"+pattern, true)
+	if !ok {
+		return nil
+	}
+	candidates := make(map[string]bool)
+	for name, entry := range files {
+		candidates[name] = entry.header.Typeflag == tar.TypeDir
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			candidates[dir] = true
+		}
+	}
+	res := make([]string, 0)
+	for name, isDir := range candidates {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(p.segments, strings.Split(name, "/")) {
+			res = append(res, name)
+		}
+	}
+	sort.Strings(res)
+	pruned := res[:0]
+	for _, name := range res {
+		if len(pruned) > 0 && isBelow(name, pruned[len(pruned)-1]) {
+			continue
+		}
+		pruned = append(pruned, name)
+	}
+	return pruned
+}
+
+func hasGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// maxLinkDepth bounds the number of links followed when resolving a file
+const maxLinkDepth = 40
+
+// fileContent is an entry of an image along with its content, read for regular files only
+type fileContent struct {
+	header  *tar.Header
+	content []byte
+}
+
+// lookupFiles walks the layers top-down for the entries named names, honoring whiteouts.
+// Missing files are absent from the result.
+func lookupFiles(layers []v1.Layer, names []string) (map[string]*fileContent, error) {
+	res := make(map[string]*fileContent)
+	pending := make(map[string]bool)
+	for _, name := range names {
+		pending[name] = true
+	}
+	for index := len(layers) - 1; index >= 0 && len(pending) > 0; index-- {
+		hidden := make(map[string]bool)
+		err := readLayer(layers[index], func(th *tar.Header, r io.Reader) error {
+			if pending[th.Name] && !IsWhiteout(th.Name) {
+				found := &fileContent{header: th}
+				if th.Typeflag == tar.TypeReg || th.Typeflag == tar.TypeRegA {
+					data, err := ioutil.ReadAll(r)
+					if err != nil {
+						return err
+					}
+					found.content = data
+				}
+				res[th.Name] = found
+				return nil
+			}
+			base := path.Base(th.Name)
+			dir := path.Dir(th.Name)
+			if dir == "." {
+				dir = ""
+			}
+			for name := range pending {
+				switch {
+				case base == OpaqueWhiteout:
+					if dir != name && isBelow(name, dir) {
+						hidden[name] = true
+					}
+				case strings.HasPrefix(base, WhiteoutPrefix):
+					if isBelow(name, path.Join(dir, strings.TrimPrefix(base, WhiteoutPrefix))) {
+						hidden[name] = true
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("reading layer %d: %v", index, err)
+		}
+		for name := range pending {
+			if res[name] != nil || hidden[name] {
+				delete(pending, name)
+			}
+		}
+	}
+	return res, nil
+}
+
+// linkTarget returns the entry name the symlink named name points to, kept within the image root
+func linkTarget(name string, th *tar.Header) string {
+	target := th.Linkname
+	if !path.IsAbs(target) {
+		target = path.Join(path.Dir("/"+name), target)
+	}
+	return entryPath(target)
+}
+
+// resolveParents replaces the first parent directory of name that is a symlink in found by the link target
+func resolveParents(found map[string]*fileContent, name string) (string, bool) {
+	segments := strings.Split(name, "/")
+	for n := 1; n < len(segments); n++ {
+		dir := strings.Join(segments[:n], "/")
+		if entry, ok := found[dir]; ok && entry.header.Typeflag == tar.TypeSymlink {
+			return entryPath(path.Join(linkTarget(dir, entry.header), strings.Join(segments[n:], "/"))), true
+		}
+	}
+	return name, false
+}
+
+// readFiles looks the in-image paths up, following links, including the ones of their parent directories,
+// and returns the entries found by path
+func readFiles(layers []v1.Layer, paths []string) (map[string]*fileContent, error) {
+	res := make(map[string]*fileContent)
+	targets := make(map[string]string)
+	for _, p := range paths {
+		targets[p] = entryPath(p)
+	}
+	for depth := 0; depth < maxLinkDepth && len(targets) > 0; depth++ {
+		names := make([]string, 0, len(targets))
+		for _, name := range targets {
+			names = append(names, name)
+			for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+				names = append(names, dir)
+			}
+		}
+		found, err := lookupFiles(layers, names)
+		if err != nil {
+			return nil, err
+		}
+		for p, name := range targets {
+			if resolved, ok := resolveParents(found, name); ok {
+				targets[p] = resolved
+				continue
+			}
+			entry, ok := found[name]
+			if !ok {
+				delete(targets, p)
+				continue
+			}
+			switch entry.header.Typeflag {
+			case tar.TypeSymlink:
+				targets[p] = linkTarget(name, entry.header)
+			case tar.TypeLink:
+				targets[p] = entry.header.Linkname
+			default:
+				if entry.header.Typeflag == tar.TypeRegA {
+					entry.header.Typeflag = tar.TypeReg
+				}
+				res[p] = entry
+				delete(targets, p)
+			}
+		}
+	}
+	for p := range targets {
+		return nil, fmt.Errorf("%s: too many levels of links", p)
+	}
+	return res, nil
+}
+
+// ReadFile returns the header and content of the regular file at the in-image path p, following links.
+// Layers are walked from the top, files deleted by a whiteout are reported missing.
+func (i *Image) ReadFile(p string) (*tar.Header, []byte, error) {
+	layers, err := i.img.Layers()
+	if err != nil {
+		return nil, nil, err
+	}
+	files, err := readFiles(layers, []string{p})
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v in %s", err, i)
+	}
+	file, ok := files[p]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: no such file in %s", p, i)
+	}
+	if file.header.Typeflag != tar.TypeReg {
+		return nil, nil, fmt.Errorf("%s: not a regular file in %s", p, i)
+	}
+	return file.header, file.content, nil
+}
+
+// PatchFile appends a layer holding the file at p rewritten by fn, keeping its original mode and ownership
+func (i *Image) PatchFile(p string, fn func(content []byte) ([]byte, error)) error {
+	th, content, err := i.ReadFile(p)
+	if err != nil {
+		return err
+	}
+	patched, err := fn(content)
+	if err != nil {
+		return fmt.Errorf("patching %s: %v", p, err)
+	}
+	header := *th
+	header.Size = int64(len(patched))
+	header.ModTime = entryModTime
+	builder := NewLayerBuilder()
+	builder.AddHeader(&header, patched)
+	layer, err := builder.Build("", nil)
+	if err != nil {
+		return err
+	}
+	return i.AppendLayer(layer, v1.History{CreatedBy: "ocilot patchFile " + p})
+}
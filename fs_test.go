@@ -0,0 +1,150 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"archive/tar"
+	"testing"
+)
+
+// baseImage returns an image of a single layer holding the given regular files
+func baseImage(t *testing.T, files map[string]string) *Image {
+	headers := make([]*tar.Header, 0, len(files))
+	for name := range files {
+		headers = append(headers, &tar.Header{Name: name, Typeflag: tar.TypeReg})
+	}
+	return layersImage(t, headerLayer(t, headers, files))
+}
+
+func TestRemove(t *testing.T) {
+	image := baseImage(t, map[string]string{
+		"usr/share/doc/a":  "a",
+		"usr/share/doc/b":  "b",
+		"var/cache/apk/x":  "x",
+		"var/cache/apk/y":  "y",
+		"etc/default.conf": "conf",
+	})
+	err := image.Remove([]string{"/usr/share/doc", "/var/cache/apk/*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := image.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 2 {
+		t.Fatalf("expected a whiteout layer to be appended, got %d layers", len(layers))
+	}
+	headers := layerHeaders(t, layers[1])
+	for _, name := range []string{"usr/share/.wh.doc", "var/cache/apk/.wh.x", "var/cache/apk/.wh.y"} {
+		if _, ok := headers[name]; !ok {
+			t.Errorf("missing whiteout %s in %v", name, headers)
+		}
+	}
+	if len(headers) != 3 {
+		t.Errorf("expected 3 whiteouts, got %v", headers)
+	}
+	for _, name := range []string{"/usr/share/doc/a", "/var/cache/apk/y"} {
+		if _, _, err = image.ReadFile(name); err == nil {
+			t.Errorf("expected %s to be removed", name)
+		}
+	}
+	if content := readContent(t, image, "/etc/default.conf"); content != "conf" {
+		t.Errorf("expected the other files to be kept, got %q", content)
+	}
+}
+
+func TestRemoveMissing(t *testing.T) {
+	image := baseImage(t, map[string]string{"etc/default.conf": "conf"})
+	if err := image.Remove([]string{"/etc/missing.conf"}); err == nil {
+		t.Error("expected removing a missing file to fail")
+	}
+	err := image.Remove([]string{"/var/cache/*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := image.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 1 {
+		t.Errorf("expected a glob matching nothing not to add a layer, got %d layers", len(layers))
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	image := layersImage(t,
+		headerLayer(t, []*tar.Header{
+			{Name: "etc/os-release", Typeflag: tar.TypeReg},
+			{Name: "etc/old.conf", Typeflag: tar.TypeReg},
+		}, map[string]string{"etc/os-release": "v1", "etc/old.conf": "old"}),
+		headerLayer(t, []*tar.Header{
+			{Name: "etc/os-release", Typeflag: tar.TypeReg},
+			{Name: "etc/.wh.old.conf", Typeflag: tar.TypeReg},
+		}, map[string]string{"etc/os-release": "v2"}),
+	)
+	if content := readContent(t, image, "/etc/os-release"); content != "v2" {
+		t.Errorf("expected the top layer content, got %q", content)
+	}
+	if _, _, err := image.ReadFile("/etc/old.conf"); err == nil {
+		t.Error("expected a whited out file to be missing")
+	}
+	if _, _, err := image.ReadFile("/etc"); err == nil {
+		t.Error("expected reading a directory to fail")
+	}
+}
+
+func TestReadFileLinks(t *testing.T) {
+	image := layersImage(t, headerLayer(t, []*tar.Header{
+		{Name: "usr/lib/os-release", Typeflag: tar.TypeReg},
+		{Name: "lib", Typeflag: tar.TypeSymlink, Linkname: "usr/lib"},
+		{Name: "etc/os-release", Typeflag: tar.TypeSymlink, Linkname: "../../../lib/os-release"},
+		{Name: "etc/hard", Typeflag: tar.TypeLink, Linkname: "usr/lib/os-release"},
+		{Name: "etc/loop", Typeflag: tar.TypeSymlink, Linkname: "loop"},
+	}, map[string]string{"usr/lib/os-release": "merged"}))
+	for _, name := range []string{"/lib/os-release", "/etc/os-release", "/etc/hard"} {
+		if content := readContent(t, image, name); content != "merged" {
+			t.Errorf("expected %s to lead to the merged usr file, got %q", name, content)
+		}
+	}
+	if _, _, err := image.ReadFile("/etc/loop"); err == nil {
+		t.Error("expected a symlink loop to fail")
+	}
+}
+
+func TestPatchFile(t *testing.T) {
+	image := layersImage(t, headerLayer(t, []*tar.Header{
+		{Name: "etc/app.conf", Typeflag: tar.TypeReg, Mode: 0640, Uid: 1000, Gid: 100},
+	}, map[string]string{"etc/app.conf": "debug=false"}))
+	err := image.PatchFile("/etc/app.conf", func(content []byte) ([]byte, error) {
+		return append(content, "\nport=80"...), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	th, content, err := image.ReadFile("/etc/app.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "debug=false\nport=80" {
+		t.Errorf("expected the patched content, got %q", content)
+	}
+	if th.Mode != 0640 || th.Uid != 1000 || th.Gid != 100 {
+		t.Errorf("expected mode and owner to be kept, got %o %d:%d", th.Mode, th.Uid, th.Gid)
+	}
+}
@@ -0,0 +1,235 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFiles are looked up in order at the root of a snapshot, the first one found is used
+var IgnoreFiles = []string{".ocilotignore", ".dockerignore"}
+
+// pattern is a single gitignore style rule
+type pattern struct {
+	negate   bool
+	dirOnly  bool
+	segments []string
+}
+
+// patternList matches slash separated paths relative to the snapshot root, the last matching pattern wins
+type patternList []pattern
+
+// parsePattern follows the gitignore syntax: a leading "!" negates, a trailing "/" only matches directories,
+// "**" matches any number of directories and a pattern without any other "/" matches at any depth.
+// When anchored is set, patterns are always relative to the root like in a .dockerignore file.
+func parsePattern(line string, anchored bool) (pattern, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern{}, false
+	}
+	res := pattern{}
+	if strings.HasPrefix(line, "!") {
+		res.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		res.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		anchored = true
+	}
+	line = strings.TrimPrefix(path.Clean("/"+line), "/")
+	if line == "" {
+		return pattern{}, false
+	}
+	if !anchored {
+		line = "**/" + line
+	}
+	res.segments = strings.Split(line, "/")
+	return res, true
+}
+
+func newPatternList(lines []string, anchored bool) patternList {
+	res := make(patternList, 0, len(lines))
+	for _, line := range lines {
+		if p, ok := parsePattern(line, anchored); ok {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
+
+// matches reports whether rel itself is selected by the list
+func (p patternList) matches(rel string, isDir bool) bool {
+	segments := strings.Split(rel, "/")
+	res := false
+	for _, pat := range p {
+		if pat.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(pat.segments, segments) {
+			res = !pat.negate
+		}
+	}
+	return res
+}
+
+// prefixMatch reports whether pattern could match an entry below the directory made of the name segments
+func prefixMatch(pattern []string, name []string) bool {
+	if len(pattern) == 0 {
+		return false
+	}
+	if pattern[0] == "**" {
+		return true
+	}
+	if len(name) == 0 {
+		return true
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return prefixMatch(pattern[1:], name[1:])
+}
+
+// excludes follows the .dockerignore semantics: a pattern matches rel or any of its parent directories,
+// the last matching pattern wins so that a negation can re-include an entry below an excluded directory
+func (p patternList) excludes(rel string, isDir bool) bool {
+	segments := strings.Split(rel, "/")
+	res := false
+	for _, pat := range p {
+		for n := len(segments); n > 0; n-- {
+			if pat.dirOnly && !isDir && n == len(segments) {
+				continue
+			}
+			if matchSegments(pat.segments, segments[:n]) {
+				res = !pat.negate
+				break
+			}
+		}
+	}
+	return res
+}
+
+// reincludes reports whether a negation could select an entry below the directory rel
+func (p patternList) reincludes(rel string) bool {
+	segments := strings.Split(rel, "/")
+	for _, pat := range p {
+		if pat.negate && prefixMatch(pat.segments, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesTree reports whether rel or one of its parent directories is selected by the list
+func (p patternList) matchesTree(rel string, isDir bool) bool {
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		if p.matches(dir, true) {
+			return true
+		}
+	}
+	return p.matches(rel, isDir)
+}
+
+// readIgnoreFile loads the patterns of the first ignore file found in dirPath
+func readIgnoreFile(dirPath string) (patternList, error) {
+	for _, name := range IgnoreFiles {
+		f, err := os.Open(filepath.Join(dirPath, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		lines := make([]string, 0)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		_ = f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return newPatternList(lines, name == ".dockerignore"), nil
+	}
+	return nil, nil
+}
+
+// snapshotFilter selects the entries of a snapshot from its include and exclude patterns
+type snapshotFilter struct {
+	include patternList
+	exclude patternList
+}
+
+func newSnapshotFilter(dirPath string, options *SnapshotOptions) (*snapshotFilter, error) {
+	res := &snapshotFilter{}
+	if options == nil || !options.NoIgnoreFile {
+		exclude, err := readIgnoreFile(dirPath)
+		if err != nil {
+			return nil, err
+		}
+		res.exclude = exclude
+	}
+	if options != nil {
+		res.exclude = append(res.exclude, newPatternList(options.Exclude, false)...)
+		res.include = newPatternList(options.Include, false)
+	}
+	return res, nil
+}
+
+// excluded is checked during the walk
+func (f *snapshotFilter) excluded(rel string, isDir bool) bool {
+	return rel != "." && f.exclude.excludes(rel, isDir)
+}
+
+// skipped reports whether the walk can skip an excluded directory with all its content,
+// which is not the case when a negation could re-include an entry below it
+func (f *snapshotFilter) skipped(rel string) bool {
+	return !f.exclude.reincludes(rel)
+}
+
+// included is checked once the walk is done, directories leading to included entries are kept by the caller
+func (f *snapshotFilter) included(rel string, isDir bool) bool {
+	return rel == "." || len(f.include) == 0 || f.include.matchesTree(rel, isDir)
+}
@@ -0,0 +1,87 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestPatternListExcludes(t *testing.T) {
+	list := newPatternList([]string{"build", "!build/keep", "*.log", "tmp/", "!important.log"}, true)
+	cases := []struct {
+		rel      string
+		isDir    bool
+		excluded bool
+	}{
+		{"build", true, true},
+		{"build/out.o", false, true},
+		{"build/keep", false, false},
+		{"build/keep/x", false, false},
+		{"a.log", false, true},
+		{"important.log", false, false},
+		{"tmp", true, true},
+		{"tmp", false, false},
+		{"tmp/x", false, true},
+		{"src/main.go", false, false},
+	}
+	for _, c := range cases {
+		if res := list.excludes(c.rel, c.isDir); res != c.excluded {
+			t.Errorf("%s: expected excluded %v, got %v", c.rel, c.excluded, res)
+		}
+	}
+	if !list.reincludes("build") {
+		t.Error("build: expected a negation below it")
+	}
+	if list.reincludes("tmp") {
+		t.Error("tmp: expected no negation below it")
+	}
+}
+
+func TestSnapshotNegation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-ignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTree(t, dir, map[string]string{
+		".dockerignore":      "build\n!build/dist/app\ntmp\n",
+		"build/obj/a.o":      "a",
+		"build/dist/app":     "app",
+		"build/dist/app.map": "map",
+		"tmp/cache/x":        "x",
+		"src/main.go":        "main",
+	})
+	snapshot, err := NewSnapshot(dir, &SnapshotOptions{Dest: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(snapshot.Files))
+	for _, th := range snapshot.Files {
+		names = append(names, th.Name)
+	}
+	sort.Strings(names)
+	// Excluded directories are only kept when they lead to a re-included entry
+	expected := []string{".dockerignore", "build", "build/dist", "build/dist/app", "src", "src/main.go"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected entries %v, got %v", expected, names)
+	}
+}
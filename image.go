@@ -18,13 +18,18 @@
 package ocilot
 
 import (
+	"archive/tar"
 	"encoding/json"
+	"fmt"
 	"github.com/docker/docker/client"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/daemon"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/google"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/match"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"strings"
@@ -34,12 +39,125 @@ type Image struct {
 	ref      name.Reference
 	img      v1.Image
 	isDocker bool
+	// layout is the OCI layout directory of images loaded from or cloned to an oci:// name
+	layout string
+	// platform is the one of the index descriptor the image was loaded from, it may tell a variant the config lacks
+	platform *v1.Platform
+	// baseName and base are the image this one was loaded as, used for the base annotations.
+	// baseName is the fully qualified reference of a registry or docker:// image, empty for layouts.
+	baseName string
+	base     v1.Image
 }
 
 var keyChain = authn.NewMultiKeychain(authn.DefaultKeychain, google.Keychain)
 
+// LayoutPrefix marks image names stored in an OCI layout directory, as oci://path, oci://path:name or oci://path@digest
+const LayoutPrefix = "oci://"
+
+// layoutRefName is the index annotation holding the name of an image in an OCI layout
+const layoutRefName = "org.opencontainers.image.ref.name"
+
+// parseLayoutName splits an oci:// image name into the layout directory, the optional image name and digest
+func parseLayoutName(imageName string) (string, string, string) {
+	p := strings.TrimPrefix(imageName, LayoutPrefix)
+	if at := strings.LastIndex(p, "@"); at > strings.LastIndex(p, "/") {
+		return p[:at], "", p[at+1:]
+	}
+	if sep := strings.LastIndex(p, ":"); sep > strings.LastIndex(p, "/") {
+		return p[:sep], p[sep+1:], ""
+	}
+	return p, "", ""
+}
+
+// findIndexImage returns the image with digest h of the index or of its nested indexes, nil when missing
+func findIndexImage(index v1.ImageIndex, h v1.Hash) (v1.Image, error) {
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	for _, desc := range manifest.Manifests {
+		if isIndex(desc.MediaType) {
+			child, err := index.ImageIndex(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			img, err := findIndexImage(child, h)
+			if img != nil || err != nil {
+				return img, err
+			}
+		} else if desc.Digest == h {
+			return index.Image(h)
+		}
+	}
+	return nil, nil
+}
+
+// loadLayoutImage returns the image of the layout with the given digest, or named refName, or its only image
+func loadLayoutImage(dir string, refName string, digest string) (v1.Image, error) {
+	index, err := layout.ImageIndexFromPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	if digest != "" {
+		h, err := v1.NewHash(digest)
+		if err != nil {
+			return nil, err
+		}
+		img, err := findIndexImage(index, h)
+		if err != nil {
+			return nil, err
+		}
+		if img == nil {
+			return nil, fmt.Errorf("no image %s in layout %s", digest, dir)
+		}
+		return img, nil
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	var found *v1.Descriptor
+	for n, desc := range manifest.Manifests {
+		if refName != "" && desc.Annotations[layoutRefName] != refName {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("several images in layout %s, select one with %s%s:<name>", dir, LayoutPrefix, dir)
+		}
+		found = &manifest.Manifests[n]
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no image %q in layout %s", refName, dir)
+	}
+	if isIndex(found.MediaType) {
+		return nil, fmt.Errorf("%s in layout %s is an index, load its images with pullIndex", found.Digest, dir)
+	}
+	return index.Image(found.Digest)
+}
+
+// LoadImage reads a registry, docker:// or oci:// image, recording it as the base of the images built from it
 func LoadImage(imageName string) (*Image, error) {
-	if strings.HasPrefix(imageName, "docker://") {
+	image, err := loadImage(imageName)
+	if err != nil {
+		return nil, err
+	}
+	image.baseName = image.qualifiedName()
+	image.base = image.img
+	return image, nil
+}
+
+func loadImage(imageName string) (*Image, error) {
+	if strings.HasPrefix(imageName, LayoutPrefix) {
+		dir, refName, digest := parseLayoutName(imageName)
+		img, err := loadLayoutImage(dir, refName, digest)
+		if err != nil {
+			return nil, err
+		}
+		return &Image{
+			img:    img,
+			layout: imageName,
+		}, nil
+	} else if strings.HasPrefix(imageName, "docker://") {
 		reference, err := name.ParseReference(strings.TrimPrefix(imageName, "docker://"))
 		if err != nil {
 			return nil, err
@@ -66,7 +184,26 @@ func LoadImage(imageName string) (*Image, error) {
 		if err != nil {
 			return nil, err
 		}
+		if isIndex(descriptor.MediaType) {
+			index, err := descriptor.ImageIndex()
+			if err != nil {
+				return nil, err
+			}
+			img, platform, err := platformImage(index, defaultPlatform)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", imageName, err)
+			}
+			log.Infow("pulling one platform of an index, use pullIndex to get all of them", "image", imageName, "platform", platform.String())
+			return &Image{
+				ref:      reference,
+				img:      img,
+				platform: platform,
+			}, nil
+		}
 		img, err := descriptor.Image()
+		if err != nil {
+			return nil, err
+		}
 		return &Image{
 			ref:      reference,
 			img:      img,
@@ -76,36 +213,83 @@ func LoadImage(imageName string) (*Image, error) {
 }
 
 func (i *Image) String() string {
+	if i.layout != "" {
+		return i.layout
+	}
+	if i.ref == nil {
+		return "scratch"
+	}
 	return i.ref.String()
 }
 
+// qualifiedName returns the reference of a registry or docker:// image with its registry and tag or digest,
+// empty for layout and scratch images
+func (i *Image) qualifiedName() string {
+	if i.layout != "" || i.ref == nil {
+		return ""
+	}
+	return i.ref.Name()
+}
+
 func (i *Image) Clone(targetName string) (*Image, error) {
-	if strings.HasPrefix(targetName, "docker://") {
+	clone := *i
+	clone.ref = nil
+	clone.isDocker = false
+	clone.layout = ""
+	if strings.HasPrefix(targetName, LayoutPrefix) {
+		clone.layout = targetName
+	} else if strings.HasPrefix(targetName, "docker://") {
 		reference, err := name.ParseReference(strings.TrimPrefix(targetName, "docker://"))
 		if err != nil {
 			return nil, err
 		}
-		return &Image{
-			ref:      reference,
-			img:      i.img,
-			isDocker: true,
-		}, nil
+		clone.ref = reference
+		clone.isDocker = true
 	} else {
 		reference, err := name.ParseReference(targetName)
 		if err != nil {
 			return nil, err
 		}
-		return &Image{
-			ref:      reference,
-			img:      i.img,
-			isDocker: false,
-		}, nil
+		clone.ref = reference
 	}
+	return &clone, nil
+}
 
+// pushLayout appends the image to its OCI layout directory, creating it when missing
+func (i *Image) pushLayout() error {
+	dir, refName, _ := parseLayoutName(i.layout)
+	p, err := layout.FromPath(dir)
+	if err != nil {
+		p, err = layout.Write(dir, empty.Index)
+		if err != nil {
+			return err
+		}
+	}
+	var options []layout.Option
+	if refName != "" {
+		options = append(options, layout.WithAnnotations(map[string]string{layoutRefName: refName}))
+	}
+	platform, err := i.Platform()
+	if err != nil {
+		return err
+	}
+	if platform.OS != "" {
+		options = append(options, layout.WithPlatform(*platform))
+	}
+	if refName != "" {
+		// Pushing again to a name replaces its image, a layout name must select a single one
+		return p.ReplaceImage(i.img, match.Annotation(layoutRefName, refName), options...)
+	}
+	return p.AppendImage(i.img, options...)
 }
 
 func (i *Image) Push() error {
-	if i.isDocker {
+	if i.layout == "" && i.ref == nil {
+		return fmt.Errorf("cannot push a %s image, clone it to a name first", i)
+	}
+	if i.layout != "" {
+		return i.pushLayout()
+	} else if i.isDocker {
 		tag, err := name.NewTag(i.ref.Name())
 		if err != nil {
 			return err
@@ -117,13 +301,101 @@ func (i *Image) Push() error {
 	}
 }
 
+// defaultPath is the PATH of images created from scratch
+const defaultPath = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// NewScratchImage returns an image without any layer for platform, linux/amd64 by default.
+// It has no name and must be cloned to one before being pushed.
+func NewScratchImage(platform v1.Platform) (*Image, error) {
+	if platform.OS == "" {
+		platform.OS = defaultPlatform.OS
+	}
+	if platform.Architecture == "" {
+		platform.Architecture = defaultPlatform.Architecture
+	}
+	created, err := creationTime()
+	if err != nil {
+		return nil, err
+	}
+	img, err := mutate.ConfigFile(empty.Image, &v1.ConfigFile{
+		Architecture: platform.Architecture,
+		OS:           platform.OS,
+		OSVersion:    platform.OSVersion,
+		Variant:      platform.Variant,
+		OSFeatures:   platform.OSFeatures,
+		Created:      v1.Time{Time: created},
+		RootFS: v1.RootFS{
+			Type:    "layers",
+			DiffIDs: []v1.Hash{},
+		},
+		History: []v1.History{},
+		Config: v1.Config{
+			Env: []string{defaultPath},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Image{img: img}, nil
+}
+
+// HistoryComment is the default comment of the history entries of appended layers
+const HistoryComment = "ocilot"
+
 func (i *Image) AddLayer(layer v1.Layer) error {
-	image, err := mutate.AppendLayers(i.img, layer)
+	history, err := editHistory("ocilot addLayer", "")
+	if err != nil {
+		return err
+	}
+	return i.AppendLayer(layer, history)
+}
+
+// AppendLayer appends layer with its history entry, the creation time defaulting to SOURCE_DATE_EPOCH or now
+// and the comment to HistoryComment. Layers below without history entries get blank ones to keep it aligned.
+func (i *Image) AppendLayer(layer v1.Layer, history v1.History) error {
+	if history.Created.IsZero() {
+		created, err := creationTime()
+		if err != nil {
+			return err
+		}
+		history.Created = v1.Time{Time: created}
+	}
+	if history.Comment == "" {
+		history.Comment = HistoryComment
+	}
+	history.EmptyLayer = false
+	img := i.img
+	layers, err := img.Layers()
+	if err != nil {
+		return err
+	}
+	configFile, err := img.ConfigFile()
+	if err != nil {
+		return err
+	}
+	if entries, aligned := alignedHistory(configFile, len(layers)); aligned {
+		configFile = configFile.DeepCopy()
+		configFile.History = entries
+		img, err = mutate.ConfigFile(img, configFile)
+		if err != nil {
+			return err
+		}
+	}
+	image, err := mutate.Append(img, mutate.Addendum{Layer: layer, History: history})
 	if err != nil {
 		return err
 	}
 	i.img = image
-	return nil
+	return i.fitMediaTypes()
+}
+
+// History returns the history entries of the image config, empty layer ones included
+func (i *Image) History() ([]v1.History, error) {
+	configFile, err := i.img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	return append([]v1.History{}, configFile.History...), nil
 }
 
 func (i *Image) GetConfig() (*v1.Config, error) {
@@ -158,3 +430,41 @@ func (i *Image) WithConfig(config *v1.Config) error {
 func (i *Image) Layers() ([]v1.Layer, error) {
 	return i.img.Layers()
 }
+
+// Remove appends a layer deleting the files matching the given absolute paths from the image.
+// Paths may contain globs, they are resolved against the files of the current layers.
+func (i *Image) Remove(paths []string) error {
+	layers, err := i.img.Layers()
+	if err != nil {
+		return err
+	}
+	files, err := mergedFiles(layers)
+	if err != nil {
+		return err
+	}
+	builder := NewLayerBuilder()
+	for _, p := range paths {
+		matches := matchFiles(files, p)
+		if len(matches) == 0 {
+			if !hasGlob(p) {
+				return fmt.Errorf("cannot remove %s, no such file in %s", p, i)
+			}
+			log.Warnw("nothing to remove", "pattern", p, "image", i.String())
+		}
+		for _, name := range matches {
+			th := &tar.Header{}
+			if entry, ok := files[name]; ok {
+				th = entry.header
+			}
+			builder.AddHeader(whiteoutHeader(name, th), nil)
+		}
+	}
+	if builder.Len() == 0 {
+		return nil
+	}
+	layer, err := builder.Build("", nil)
+	if err != nil {
+		return err
+	}
+	return i.AppendLayer(layer, v1.History{CreatedBy: "ocilot remove " + strings.Join(paths, " ")})
+}
@@ -0,0 +1,134 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestPushLayoutTwice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-layout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, content := range []string{"first", "second"} {
+		image := layersImage(t, buildLayer(t, "/version", content, nil))
+		target, err := image.Clone(LayoutPrefix + dir + ":v1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = target.Push()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	other, err := layersImage(t, buildLayer(t, "/version", "other", nil)).Clone(LayoutPrefix + dir + ":v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = other.Push()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pulled, err := LoadImage(LayoutPrefix + dir + ":v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content := readContent(t, pulled, "/version"); content != "second" {
+		t.Errorf("expected the last pushed image, got %q", content)
+	}
+	pulled, err = LoadImage(LayoutPrefix + dir + ":v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content := readContent(t, pulled, "/version"); content != "other" {
+		t.Errorf("expected the other name to be kept, got %q", content)
+	}
+}
+
+func TestScratchImage(t *testing.T) {
+	image, err := NewScratchImage(v1.Platform{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	platform, err := image.Platform()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if platform.String() != "linux/amd64" {
+		t.Errorf("expected linux/amd64 by default, got %s", platform)
+	}
+	layers, err := image.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 0 {
+		t.Errorf("expected no layer, got %d", len(layers))
+	}
+	configFile, err := image.img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configFile.Config.Env) != 1 || configFile.Config.Env[0] != defaultPath {
+		t.Errorf("expected the default PATH, got %v", configFile.Config.Env)
+	}
+	if err = image.Push(); err == nil {
+		t.Error("expected pushing an unnamed image to fail")
+	}
+}
+
+func TestScratchImageVariant(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-layout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	image, err := NewScratchImage(v1.Platform{Architecture: "arm", Variant: "v7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = image.AddLayer(buildLayer(t, "/a", "a", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := image.Clone(LayoutPrefix + dir + ":arm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = target.Push()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pulled, err := LoadImage(LayoutPrefix + dir + ":arm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []*Image{image, pulled} {
+		platform, err := i.Platform()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if platform.String() != "linux/arm/v7" {
+			t.Errorf("expected linux/arm/v7 for %s, got %s", i, platform)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"strings"
+)
+
+const (
+	IndexOCI    = "oci"
+	IndexDocker = "docker"
+)
+
+// IndexOptions controls how an image index is written
+type IndexOptions struct {
+	// Format is IndexOCI or IndexDocker, a Docker manifest list when all images use Docker manifests by default
+	Format string `json:"format"`
+}
+
+func isIndex(mediaType types.MediaType) bool {
+	return mediaType == types.OCIImageIndex || mediaType == types.DockerManifestList
+}
+
+// attestationRefType is the annotation of the attestation manifests buildx adds to an index
+const attestationRefType = "vnd.docker.reference.type"
+
+// isAttestation reports whether an index entry holds the attestations of an image instead of a platform image
+func isAttestation(desc v1.Descriptor) bool {
+	if _, ok := desc.Annotations[attestationRefType]; ok {
+		return true
+	}
+	return desc.Platform != nil && (desc.Platform.OS == "unknown" || desc.Platform.Architecture == "unknown")
+}
+
+// defaultPlatform is the platform of the images pulled from an index and of the scratch images
+var defaultPlatform = v1.Platform{OS: "linux", Architecture: "amd64"}
+
+// platformImage returns the image of index for platform, its variant being only checked when set
+func platformImage(index v1.ImageIndex, platform v1.Platform) (v1.Image, *v1.Platform, error) {
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, desc := range manifest.Manifests {
+		if isIndex(desc.MediaType) || isAttestation(desc) || desc.Platform == nil {
+			continue
+		}
+		if desc.Platform.OS != platform.OS || desc.Platform.Architecture != platform.Architecture {
+			continue
+		}
+		if platform.Variant != "" && desc.Platform.Variant != platform.Variant {
+			continue
+		}
+		img, err := index.Image(desc.Digest)
+		if err != nil {
+			return nil, nil, err
+		}
+		return img, desc.Platform, nil
+	}
+	return nil, nil, fmt.Errorf("no %s image in index, load its platform images with pullIndex", platform.String())
+}
+
+// Platform returns the platform of the image read from its config,
+// the variant coming from the index the image was loaded from when the config has none
+func (i *Image) Platform() (*v1.Platform, error) {
+	configFile, err := i.img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	res := configFile.Platform()
+	if res == nil {
+		res = &v1.Platform{}
+	}
+	if res.Variant == "" && i.platform != nil {
+		res.Variant = i.platform.Variant
+	}
+	return res, nil
+}
+
+// indexImages returns the images of an index, named by digest in repo when not nil or in the layout directory otherwise
+func indexImages(index v1.ImageIndex, repo *name.Repository, layoutDir string) ([]*Image, error) {
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*Image, 0, len(manifest.Manifests))
+	for _, desc := range manifest.Manifests {
+		if isIndex(desc.MediaType) {
+			log.Warnw("skipping nested index", "digest", desc.Digest.String())
+			continue
+		}
+		if isAttestation(desc) {
+			log.Debugw("skipping attestation manifest", "digest", desc.Digest.String())
+			continue
+		}
+		img, err := index.Image(desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		image := &Image{img: img, platform: desc.Platform}
+		if repo != nil {
+			image.ref = repo.Digest(desc.Digest.String())
+		} else {
+			image.layout = LayoutPrefix + layoutDir + "@" + desc.Digest.String()
+		}
+		image.baseName = image.qualifiedName()
+		image.base = img
+		res = append(res, image)
+	}
+	return res, nil
+}
+
+// LoadIndex returns the platform images of a multi-platform registry or oci:// image.
+// Each image is named by its digest, a single platform image is returned alone.
+func LoadIndex(imageName string) ([]*Image, error) {
+	if strings.HasPrefix(imageName, LayoutPrefix) {
+		dir, refName, _ := parseLayoutName(imageName)
+		index, err := layout.ImageIndexFromPath(dir)
+		if err != nil {
+			return nil, err
+		}
+		if refName != "" {
+			manifest, err := index.IndexManifest()
+			if err != nil {
+				return nil, err
+			}
+			found := false
+			for _, desc := range manifest.Manifests {
+				if desc.Annotations[layoutRefName] != refName {
+					continue
+				}
+				found = true
+				if !isIndex(desc.MediaType) {
+					image, err := LoadImage(imageName)
+					if err != nil {
+						return nil, err
+					}
+					return []*Image{image}, nil
+				}
+				index, err = index.ImageIndex(desc.Digest)
+				if err != nil {
+					return nil, err
+				}
+				break
+			}
+			if !found {
+				return nil, fmt.Errorf("no image %q in layout %s", refName, dir)
+			}
+		}
+		return indexImages(index, nil, dir)
+	} else if strings.HasPrefix(imageName, "docker://") {
+		return nil, fmt.Errorf("the docker daemon does not store image indexes, cannot load %s", imageName)
+	}
+	reference, err := name.ParseReference(imageName)
+	if err != nil {
+		return nil, err
+	}
+	descriptor, err := remote.Get(reference, remote.WithAuthFromKeychain(keyChain))
+	if err != nil {
+		return nil, err
+	}
+	if !isIndex(descriptor.MediaType) {
+		image, err := LoadImage(imageName)
+		if err != nil {
+			return nil, err
+		}
+		return []*Image{image}, nil
+	}
+	index, err := descriptor.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	repo := reference.Context()
+	return indexImages(index, &repo, "")
+}
+
+// IsIndex reports whether imageName is a multi-platform registry or oci:// image, of which LoadImage only loads one platform
+func IsIndex(imageName string) (bool, error) {
+	if strings.HasPrefix(imageName, LayoutPrefix) {
+		dir, refName, digest := parseLayoutName(imageName)
+		if digest != "" {
+			return false, nil
+		}
+		index, err := layout.ImageIndexFromPath(dir)
+		if err != nil {
+			return false, err
+		}
+		manifest, err := index.IndexManifest()
+		if err != nil {
+			return false, err
+		}
+		for _, desc := range manifest.Manifests {
+			if (refName == "" || desc.Annotations[layoutRefName] == refName) && isIndex(desc.MediaType) {
+				return true, nil
+			}
+		}
+		return false, nil
+	} else if strings.HasPrefix(imageName, "docker://") {
+		return false, nil
+	}
+	reference, err := name.ParseReference(imageName)
+	if err != nil {
+		return false, err
+	}
+	descriptor, err := remote.Head(reference, remote.WithAuthFromKeychain(keyChain))
+	if err != nil {
+		return false, err
+	}
+	return isIndex(descriptor.MediaType), nil
+}
+
+// PushIndex writes images as one multi-platform image named targetName, in a registry or an oci:// layout
+func PushIndex(targetName string, images []*Image, options *IndexOptions) error {
+	if strings.HasPrefix(targetName, "docker://") {
+		return fmt.Errorf("the docker daemon does not store image indexes, cannot push %s", targetName)
+	}
+	if len(images) == 0 {
+		return fmt.Errorf("cannot push %s, no image given", targetName)
+	}
+	format := ""
+	if options != nil {
+		format = options.Format
+	}
+	adds := make([]mutate.IndexAddendum, 0, len(images))
+	allDocker := true
+	for _, image := range images {
+		platform, err := image.Platform()
+		if err != nil {
+			return err
+		}
+		mediaType, err := image.img.MediaType()
+		if err != nil {
+			return err
+		}
+		if mediaType != types.DockerManifestSchema2 {
+			if format == IndexDocker {
+				return fmt.Errorf("cannot push %s as a docker manifest list, %s is a %s image", targetName, image.String(), mediaType)
+			}
+			allDocker = false
+		}
+		adds = append(adds, mutate.IndexAddendum{
+			Add: image.img,
+			Descriptor: v1.Descriptor{
+				Platform: platform,
+			},
+		})
+	}
+	var index v1.ImageIndex
+	switch format {
+	case "":
+		if allDocker {
+			index = mutate.IndexMediaType(empty.Index, types.DockerManifestList)
+		} else {
+			index = empty.Index
+		}
+	case IndexDocker:
+		index = mutate.IndexMediaType(empty.Index, types.DockerManifestList)
+	case IndexOCI:
+		index = empty.Index
+	default:
+		return fmt.Errorf("unknown index format %q, expected %s or %s", format, IndexOCI, IndexDocker)
+	}
+	index = mutate.AppendManifests(index, adds...)
+	if strings.HasPrefix(targetName, LayoutPrefix) {
+		dir, refName, _ := parseLayoutName(targetName)
+		p, err := layout.FromPath(dir)
+		if err != nil {
+			p, err = layout.Write(dir, empty.Index)
+			if err != nil {
+				return err
+			}
+		}
+		if refName != "" {
+			annotations := layout.WithAnnotations(map[string]string{layoutRefName: refName})
+			return p.ReplaceIndex(index, match.Annotation(layoutRefName, refName), annotations)
+		}
+		return p.AppendIndex(index)
+	}
+	reference, err := name.ParseReference(targetName)
+	if err != nil {
+		return err
+	}
+	return remote.WriteIndex(reference, index, remote.WithAuthFromKeychain(keyChain))
+}
@@ -0,0 +1,178 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func platformIndex(t *testing.T, platforms ...v1.Platform) v1.ImageIndex {
+	adds := make([]mutate.IndexAddendum, 0, len(platforms))
+	for n := range platforms {
+		image := layersImage(t, buildLayer(t, "/platform", platforms[n].String(), nil))
+		adds = append(adds, mutate.IndexAddendum{
+			Add:        image.img,
+			Descriptor: v1.Descriptor{Platform: &platforms[n]},
+		})
+	}
+	return mutate.AppendManifests(empty.Index, adds...)
+}
+
+func TestPlatformImage(t *testing.T) {
+	index := platformIndex(t,
+		v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+		v1.Platform{OS: "linux", Architecture: "amd64"},
+	)
+	img, platform, err := platformImage(index, defaultPlatform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if platform.Architecture != "amd64" {
+		t.Errorf("expected the amd64 image, got %s", platform.String())
+	}
+	if content := readContent(t, &Image{img: img}, "/platform"); content != "linux/amd64" {
+		t.Errorf("expected the amd64 image content, got %q", content)
+	}
+	_, _, err = platformImage(index, v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v7"})
+	if err == nil {
+		t.Error("expected a missing platform to fail")
+	}
+}
+
+func TestPushIndexDockerRejectsOCI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	docker := layersImage(t, buildLayer(t, "/a", "a", nil))
+	oci := layersImage(t, buildLayer(t, "/a", "a", &LayerOptions{Compression: CompressionZstd}))
+	err = PushIndex(LayoutPrefix+dir+":docker", []*Image{docker, oci}, &IndexOptions{Format: IndexDocker})
+	if err == nil {
+		t.Fatal("expected an OCI image to be rejected from a docker manifest list")
+	}
+	err = PushIndex(LayoutPrefix+dir+":oci", []*Image{docker, oci}, &IndexOptions{Format: IndexOCI})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadIndexSkipsAttestations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	index := platformIndex(t, v1.Platform{OS: "linux", Architecture: "amd64"}, v1.Platform{OS: "linux", Architecture: "arm64"})
+	attestation := layersImage(t, buildLayer(t, "/attestation", "{}", nil))
+	index = mutate.AppendManifests(index, mutate.IndexAddendum{
+		Add: attestation.img,
+		Descriptor: v1.Descriptor{
+			Platform:    &v1.Platform{OS: "unknown", Architecture: "unknown"},
+			Annotations: map[string]string{attestationRefType: "attestation-manifest"},
+		},
+	})
+	_, err = layout.Write(dir, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	images, err := LoadIndex(LayoutPrefix + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 2 {
+		t.Fatalf("expected the 2 platform images, got %d", len(images))
+	}
+	for _, image := range images {
+		platform, err := image.Platform()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if platform.OS != "linux" {
+			t.Errorf("expected only linux images, got %s", platform)
+		}
+	}
+}
+
+func TestPushIndexTwice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, count := range []int{1, 2} {
+		images := make([]*Image, 0, count)
+		for n := 0; n < count; n++ {
+			image, err := NewScratchImage(v1.Platform{Architecture: []string{"amd64", "arm64"}[n]})
+			if err != nil {
+				t.Fatal(err)
+			}
+			images = append(images, image)
+		}
+		err = PushIndex(LayoutPrefix+dir+":multi", images, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	images, err := LoadIndex(LayoutPrefix + dir + ":multi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 2 {
+		t.Errorf("expected the last pushed index, got %d images", len(images))
+	}
+}
+
+func TestIsIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	image, err := NewScratchImage(v1.Platform{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = PushIndex(LayoutPrefix+dir+":multi", []*Image{image}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	single, err := image.Clone(LayoutPrefix + dir + ":single")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = single.Push()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]bool{"multi": true, "single": false} {
+		multiPlatform, err := IsIndex(LayoutPrefix + dir + ":" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if multiPlatform != expected {
+			t.Errorf("expected %s to be an index: %v, got %v", name, expected, multiPlatform)
+		}
+	}
+}
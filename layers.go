@@ -0,0 +1,356 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"strings"
+)
+
+// alignedHistory returns the history of an image with exactly one layer entry per layer, count being the layer count.
+// Missing entries are assumed to be the ones of the lowest layers and added blank, surplus entries of the lowest layers
+// are kept as empty layer entries. Both cases are logged, as the history was describing other layers.
+// The returned flag tells whether the history had to be aligned.
+func alignedHistory(configFile *v1.ConfigFile, count int) ([]v1.History, bool) {
+	nonEmpty := 0
+	for _, h := range configFile.History {
+		if !h.EmptyLayer {
+			nonEmpty++
+		}
+	}
+	if nonEmpty == count {
+		return configFile.History, false
+	}
+	log.Warnw("image history does not match its layers", "entries", nonEmpty, "layers", count)
+	if nonEmpty < count {
+		missing := make([]v1.History, count-nonEmpty)
+		for n := range missing {
+			missing[n].Created = configFile.Created
+		}
+		return append(missing, configFile.History...), true
+	}
+	res := make([]v1.History, len(configFile.History))
+	surplus := nonEmpty - count
+	for n, h := range configFile.History {
+		if !h.EmptyLayer && surplus > 0 {
+			h.EmptyLayer = true
+			surplus--
+		}
+		res[n] = h
+	}
+	return res, true
+}
+
+// layerHistory splits the history of an image by layer, each group ending with the entry of its layer
+// and starting with the empty layer entries before it. Entries after the last layer are returned apart.
+// A history not matching the layers is aligned first.
+func layerHistory(configFile *v1.ConfigFile, count int) ([][]v1.History, []v1.History) {
+	groups := make([][]v1.History, count)
+	current := make([]v1.History, 0)
+	n := 0
+	history, _ := alignedHistory(configFile, count)
+	for _, h := range history {
+		current = append(current, h)
+		if !h.EmptyLayer {
+			groups[n] = current
+			current = make([]v1.History, 0)
+			n++
+		}
+	}
+	return groups, current
+}
+
+// ociLayerTypes are the OCI equivalents of the Docker layer media types
+var ociLayerTypes = map[types.MediaType]types.MediaType{
+	types.DockerLayer:             types.OCILayer,
+	types.DockerUncompressedLayer: types.OCIUncompressedLayer,
+}
+
+// ociLayer reports the OCI media type of a Docker layer
+type ociLayer struct {
+	v1.Layer
+	mediaType types.MediaType
+}
+
+func (l *ociLayer) MediaType() (types.MediaType, error) {
+	return l.mediaType, nil
+}
+
+// dockerLayer reports whether a Docker schema2 manifest can describe a layer of the given media type
+func dockerLayer(mediaType types.MediaType) bool {
+	_, ok := ociLayerTypes[mediaType]
+	return ok || mediaType == types.DockerForeignLayer
+}
+
+// fitMediaTypes switches the image to an OCI manifest when one of its layers cannot be described by its Docker manifest,
+// and gives the Docker layers of an OCI manifest their OCI media types
+func (i *Image) fitMediaTypes() error {
+	manifest, err := i.img.Manifest()
+	if err != nil {
+		return err
+	}
+	for _, layer := range manifest.Layers {
+		_, docker := ociLayerTypes[layer.MediaType]
+		if (manifest.MediaType == types.DockerManifestSchema2 && !dockerLayer(layer.MediaType)) ||
+			(manifest.MediaType == types.OCIManifestSchema1 && docker) {
+			return i.editLayers(func(layers []v1.Layer, groups [][]v1.History, trailing []v1.History) ([]v1.Layer, [][]v1.History, []v1.History, error) {
+				return layers, groups, trailing, nil
+			})
+		}
+	}
+	return nil
+}
+
+// rebuildImage returns img with the given layers, their history groups and the trailing history entries,
+// keeping the config and media types of img. A Docker image gets OCI media types when one of the layers needs them,
+// and the layers of an OCI image get OCI media types.
+func rebuildImage(img v1.Image, layers []v1.Layer, groups [][]v1.History, trailing []v1.History) (v1.Image, error) {
+	configFile, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	configFile = configFile.DeepCopy()
+	configFile.RootFS.DiffIDs = []v1.Hash{}
+	configFile.History = nil
+	base, err := mutate.ConfigFile(empty.Image, configFile)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	mediaType, configMediaType := manifest.MediaType, manifest.Config.MediaType
+	if mediaType == types.DockerManifestSchema2 {
+		for _, layer := range layers {
+			layerType, err := layer.MediaType()
+			if err != nil {
+				return nil, err
+			}
+			if !dockerLayer(layerType) {
+				mediaType, configMediaType = types.OCIManifestSchema1, types.OCIConfigJSON
+				break
+			}
+		}
+	}
+	if mediaType == types.OCIManifestSchema1 {
+		converted := make([]v1.Layer, len(layers))
+		for n, layer := range layers {
+			converted[n] = layer
+			layerType, err := layer.MediaType()
+			if err != nil {
+				return nil, err
+			}
+			if ociType, ok := ociLayerTypes[layerType]; ok {
+				converted[n] = &ociLayer{Layer: layer, mediaType: ociType}
+			}
+		}
+		layers = converted
+	}
+	if mediaType == "" {
+		mediaType = types.DockerManifestSchema2
+	}
+	base = mutate.MediaType(base, mediaType)
+	if configMediaType != "" {
+		base = mutate.ConfigMediaType(base, configMediaType)
+	}
+	adds := make([]mutate.Addendum, 0, len(layers))
+	history := make([]v1.History, 0)
+	describe := true
+	for n, layer := range layers {
+		add := mutate.Addendum{Layer: layer}
+		if len(groups[n]) == 0 {
+			describe = false
+		} else {
+			add.History = groups[n][len(groups[n])-1]
+			history = append(history, groups[n]...)
+		}
+		adds = append(adds, add)
+	}
+	res, err := mutate.Append(base, adds...)
+	if err != nil {
+		return nil, err
+	}
+	if !describe {
+		// Only part of the layers have a history, it is dropped rather than being misaligned
+		history = nil
+	} else {
+		history = append(history, trailing...)
+	}
+	rebuilt, err := res.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	rebuilt = rebuilt.DeepCopy()
+	rebuilt.History = history
+	res, err = mutate.ConfigFile(res, rebuilt)
+	if err != nil {
+		return nil, err
+	}
+	// Manifest and layer annotations are kept, layer ones follow their layer
+	annotations := make(map[v1.Hash]map[string]string)
+	for _, layer := range manifest.Layers {
+		if len(layer.Annotations) > 0 {
+			annotations[layer.Digest] = layer.Annotations
+		}
+	}
+	if len(manifest.Annotations) == 0 && len(annotations) == 0 {
+		return res, nil
+	}
+	return annotate(res, manifest.Annotations, annotations), nil
+}
+
+// editLayers applies edit to the layers of the image and their history groups, then rebuilds it.
+// Trailing history entries are the ones of config only steps after the last layer.
+func (i *Image) editLayers(edit func(layers []v1.Layer, groups [][]v1.History, trailing []v1.History) ([]v1.Layer, [][]v1.History, []v1.History, error)) error {
+	layers, err := i.img.Layers()
+	if err != nil {
+		return err
+	}
+	configFile, err := i.img.ConfigFile()
+	if err != nil {
+		return err
+	}
+	groups, trailing := layerHistory(configFile, len(layers))
+	layers, groups, trailing, err = edit(layers, groups, trailing)
+	if err != nil {
+		return err
+	}
+	img, err := rebuildImage(i.img, layers, groups, trailing)
+	if err != nil {
+		return err
+	}
+	i.img = img
+	return nil
+}
+
+// editHistory returns the history entry of a layer edited by ocilot
+func editHistory(createdBy string, comment string) (v1.History, error) {
+	created, err := creationTime()
+	if err != nil {
+		return v1.History{}, err
+	}
+	return v1.History{
+		Created:   v1.Time{Time: created},
+		CreatedBy: createdBy,
+		Comment:   comment,
+	}, nil
+}
+
+func (i *Image) checkLayerIndex(index int, count int) error {
+	if index < 0 || index >= count {
+		return fmt.Errorf("no layer %d in %s, it has %d layers", index, i, count)
+	}
+	return nil
+}
+
+// LayerIndex returns the position of the layer having the given digest or diff id, the algorithm prefix being optional
+func (i *Image) LayerIndex(digest string) (int, error) {
+	if !strings.Contains(digest, ":") {
+		digest = "sha256:" + digest
+	}
+	layers, err := i.img.Layers()
+	if err != nil {
+		return 0, err
+	}
+	for index, layer := range layers {
+		layerDigest, err := layer.Digest()
+		if err != nil {
+			return 0, err
+		}
+		diffID, err := layer.DiffID()
+		if err != nil {
+			return 0, err
+		}
+		if layerDigest.String() == digest || diffID.String() == digest {
+			return index, nil
+		}
+	}
+	return 0, fmt.Errorf("no layer %s in %s", digest, i)
+}
+
+// RemoveLayer drops the layer at index, the history entries of config only steps before it being kept
+func (i *Image) RemoveLayer(index int) error {
+	return i.editLayers(func(layers []v1.Layer, groups [][]v1.History, trailing []v1.History) ([]v1.Layer, [][]v1.History, []v1.History, error) {
+		err := i.checkLayerIndex(index, len(layers))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		kept := make([]v1.History, 0)
+		for _, h := range groups[index] {
+			if h.EmptyLayer {
+				kept = append(kept, h)
+			}
+		}
+		layers = append(layers[:index:index], layers[index+1:]...)
+		groups = append(groups[:index:index], groups[index+1:]...)
+		if index == len(groups) {
+			trailing = append(kept, trailing...)
+		} else {
+			groups[index] = append(kept, groups[index]...)
+		}
+		return layers, groups, trailing, nil
+	})
+}
+
+// ReplaceLayer swaps the layer at index with layer
+func (i *Image) ReplaceLayer(index int, layer v1.Layer) error {
+	return i.editLayers(func(layers []v1.Layer, groups [][]v1.History, trailing []v1.History) ([]v1.Layer, [][]v1.History, []v1.History, error) {
+		err := i.checkLayerIndex(index, len(layers))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		previous, err := layers[index].Digest()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		history, err := editHistory("ocilot replaceLayer", "replaces "+previous.String())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		layers = append(layers[:index:index], layers[index:]...)
+		layers[index] = layer
+		group := groups[index]
+		groups[index] = append(group[:len(group)-1:len(group)-1], history)
+		return layers, groups, trailing, nil
+	})
+}
+
+// InsertLayer adds layer at index, moving the layers from index up, index being the layer count to append it
+func (i *Image) InsertLayer(index int, layer v1.Layer) error {
+	return i.editLayers(func(layers []v1.Layer, groups [][]v1.History, trailing []v1.History) ([]v1.Layer, [][]v1.History, []v1.History, error) {
+		if index != len(layers) {
+			err := i.checkLayerIndex(index, len(layers))
+			if err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		history, err := editHistory("ocilot insertLayer", "")
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		layers = append(layers[:index:index], append([]v1.Layer{layer}, layers[index:]...)...)
+		group := []v1.History{history}
+		groups = append(groups[:index:index], append([][]v1.History{group}, groups[index:]...)...)
+		return layers, groups, trailing, nil
+	})
+}
@@ -0,0 +1,80 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"testing"
+)
+
+// dropHistory keeps only the last history entries of image
+func dropHistory(t *testing.T, image *Image, kept int) {
+	configFile, err := image.img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	configFile = configFile.DeepCopy()
+	configFile.History = configFile.History[len(configFile.History)-kept:]
+	image.img, err = mutate.ConfigFile(image.img, configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRemoveLayerMissingHistory(t *testing.T) {
+	image := layersImage(t,
+		buildLayer(t, "/a", "a", nil),
+		buildLayer(t, "/b", "b", nil),
+	)
+	err := image.AppendLayer(buildLayer(t, "/c", "c", nil), v1.History{CreatedBy: "top"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropHistory(t, image, 1)
+	err = image.RemoveLayer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := image.History()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[1].CreatedBy != "top" {
+		t.Fatalf("expected the top entry to be kept over a padded one, got %+v", history)
+	}
+}
+
+func TestInsertLayerHistoryEpoch(t *testing.T) {
+	setEpoch(t, "1600000000")
+	image := layersImage(t, buildLayer(t, "/a", "a", nil))
+	err := image.InsertLayer(0, buildLayer(t, "/b", "b", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := image.History()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].CreatedBy != "ocilot insertLayer" || history[0].Created.Unix() != 1600000000 {
+		t.Fatalf("expected an insertLayer entry created at the epoch, got %+v", history)
+	}
+	if history[1].CreatedBy != "ocilot addLayer" || history[1].Created.Unix() != 1600000000 {
+		t.Fatalf("expected an addLayer entry created at the epoch, got %+v", history[1])
+	}
+}
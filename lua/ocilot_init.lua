@@ -1,6 +1,14 @@
 local log = require("log")
 local box=require("luabox")
 
+-- Setters take strings, a nil value is an error rather than the "nil" string
+local checkValue = function(setter, name, value)
+    if value == nil then
+        error(setter .. ": no value given for " .. tostring(name), 3)
+    end
+    return tostring(value)
+end
+
 -- Layer
 
 local layermt = {}
@@ -35,9 +43,13 @@ snapmt.__index = function(_, key)
     return snapmt[key]
 end
 
-snapmt.asLayer = function(this, workFile)
+snapmt.asLayer = function(this, workFile, options)
     log.debug("Generating layer")
-    local layer_ud = ocisys.snapAsLayer(this._ud, workFile)
+    if type(workFile) == "table" then
+        options = workFile
+        workFile = nil
+    end
+    local layer_ud = ocisys.snapAsLayer(this._ud, workFile, options)
     return enrichLayer({ _ud = layer_ud })
 end
 
@@ -50,6 +62,14 @@ snapmt.files = function(this)
     return ocisys.snapFiles(this._ud)
 end
 
+snapmt.hash = function(this)
+    return ocisys.snapHash(this._ud)
+end
+
+snapmt.stats = function(this)
+    return ocisys.snapStats(this._ud)
+end
+
 snapmt.__tostring = function(this)
     return ocisys.snapString(this._ud)
 end
@@ -83,6 +103,54 @@ imagemt.setConfig = function(this,config)
     ocisys.imageSetConfig(this._ud,config)
 end
 
+imagemt.setEnv = function(this, key, value)
+    ocisys.imageSetEnv(this._ud, key, checkValue("setEnv", key, value))
+end
+
+imagemt.unsetEnv = function(this, key)
+    ocisys.imageUnsetEnv(this._ud, key)
+end
+
+imagemt.setEntrypoint = function(this, entrypoint)
+    ocisys.imageSetEntrypoint(this._ud, entrypoint)
+end
+
+imagemt.setCmd = function(this, cmd)
+    ocisys.imageSetCmd(this._ud, cmd)
+end
+
+imagemt.setWorkdir = function(this, dir)
+    ocisys.imageSetWorkdir(this._ud, dir)
+end
+
+imagemt.setUser = function(this, user)
+    ocisys.imageSetUser(this._ud, checkValue("setUser", "user", user))
+end
+
+imagemt.exposePort = function(this, port)
+    ocisys.imageExposePort(this._ud, checkValue("exposePort", "port", port))
+end
+
+imagemt.addVolume = function(this, volume)
+    ocisys.imageAddVolume(this._ud, volume)
+end
+
+imagemt.setLabel = function(this, key, value)
+    ocisys.imageSetLabel(this._ud, key, checkValue("setLabel", key, value))
+end
+
+imagemt.setStopSignal = function(this, signal)
+    ocisys.imageSetStopSignal(this._ud, checkValue("setStopSignal", "signal", signal))
+end
+
+-- A string test is run with the shell of the image
+imagemt.setHealthcheck = function(this, healthcheck)
+    if type(healthcheck.test) == "string" then
+        healthcheck.test = { "CMD-SHELL", healthcheck.test }
+    end
+    ocisys.imageSetHealthcheck(this._ud, healthcheck)
+end
+
 imagemt.layers = function(this)
     local layers = ocisys.imageGetLayers(this._ud)
     local res = {}
@@ -92,23 +160,177 @@ imagemt.layers = function(this)
     return res
 end
 
-imagemt.append = function(this, layer)
-    ocisys.imageAppendLayer(this._ud, layer._ud)
+-- history is the created_by description of the layer or a table of history fields,
+-- created_by defaults to the script name and line
+imagemt.append = function(this, layer, history)
+    if type(history) == "string" then
+        history = { created_by = history }
+    end
+    ocisys.imageAppendLayer(this._ud, layer._ud, history)
+end
+
+imagemt.history = function(this)
+    return ocisys.imageHistory(this._ud)
+end
+
+imagemt.remove = function(this, paths)
+    ocisys.imageRemove(this._ud, paths)
+end
+
+imagemt.readFile = function(this, path)
+    return ocisys.imageReadFile(this._ud, path)
+end
+
+imagemt.patchFile = function(this, path, fn)
+    log.debug("Patching file", { path = path })
+    ocisys.imagePatchFile(this._ud, path, fn)
+end
+
+imagemt.addUser = function(this, user)
+    log.debug("Adding user", { name = user.name })
+    ocisys.imageAddUser(this._ud, user)
+end
+
+imagemt.addGroup = function(this, group)
+    log.debug("Adding group", { name = group.name })
+    ocisys.imageAddGroup(this._ud, group)
+end
+
+imagemt.copyFrom = function(this, other, src, dest, options)
+    log.debug("Copying from image", { image = tostring(other), src = src, dest = dest })
+    ocisys.imageCopyFrom(this._ud, other._ud, src, dest, options)
+end
+
+imagemt.unpack = function(this, dir)
+    log.debug("Unpacking image", { image = tostring(this), dir = dir })
+    ocisys.imageUnpack(this._ud, dir)
+end
+
+imagemt.rebase = function(this, oldBase, newBase, options)
+    log.debug("Rebasing image", { image = tostring(this), from = tostring(oldBase), to = tostring(newBase) })
+    ocisys.imageRebase(this._ud, oldBase._ud, newBase._ud, options)
+end
+
+imagemt.squash = function(this, options)
+    log.debug("Squashing image", { image = tostring(this) })
+    ocisys.imageSquash(this._ud, options)
+end
+
+-- Layers are designated by their position in image:layers(), or by digest for removeLayer and replaceLayer
+imagemt.removeLayer = function(this, layer)
+    ocisys.imageRemoveLayer(this._ud, layer)
+end
+
+imagemt.replaceLayer = function(this, layer, newLayer)
+    ocisys.imageReplaceLayer(this._ud, layer, newLayer._ud)
+end
+
+imagemt.insertLayer = function(this, index, layer)
+    ocisys.imageInsertLayer(this._ud, index, layer._ud)
+end
+
+local function stringValues(values)
+    local res = {}
+    for k, v in pairs(values) do
+        res[k] = tostring(v)
+    end
+    return res
+end
+
+imagemt.annotate = function(this, annotations)
+    ocisys.imageAnnotate(this._ud, stringValues(annotations))
+end
+
+imagemt.annotateLayer = function(this, layer, annotations)
+    ocisys.imageAnnotateLayer(this._ud, layer, stringValues(annotations))
+end
+
+-- Fills the org.opencontainers.image annotations from the run context, options override version, revision and source
+imagemt.annotateStandard = function(this, options)
+    ocisys.imageAnnotateStandard(this._ud, options)
+end
+
+imagemt.platform = function(this)
+    return ocisys.imagePlatform(this._ud)
 end
 
 imagemt.__tostring = function(this)
     return ocisys.imageString(this._ud)
 end
 
+-- Image lists, the platform images of an index
+local listmt = {}
+
+local function enrichList(images)
+    setmetatable(images, listmt)
+    return images
+end
+
+listmt.__index = function(_, key)
+    return listmt[key]
+end
+
+listmt.each = function(this, fn)
+    for _, image in ipairs(this) do
+        fn(image)
+    end
+end
+
+-- filter is either a function of the image or a table of platform fields to match
+listmt.select = function(this, filter)
+    local res = {}
+    for _, image in ipairs(this) do
+        local selected = true
+        if type(filter) == "function" then
+            selected = filter(image)
+        else
+            local platform = image:platform()
+            for k, v in pairs(filter) do
+                if platform[k] ~= v then
+                    selected = false
+                end
+            end
+        end
+        if selected then
+            res[#res + 1] = image
+        end
+    end
+    return enrichList(res)
+end
+
+listmt.push = function(this, name, options)
+    pushIndex(name, this, options)
+end
+
 
 -- Globals
 
-function snapshot(dir)
+function snapshot(dir, options)
     log.debug("Generating snapshot ", { dir = dir })
-    local snap_ud = ocisys.snap(dir)
-    return enrichSnapshot({
-        _ud = snap_ud
+    local snap = enrichSnapshot({
+        _ud = ocisys.snap(dir, options)
     })
+    local stats = snap:stats()
+    log.info("Snapshot taken", { dir = dir, files = stats.files, size = stats.size })
+    return snap
+end
+
+layer = function(entries, options)
+    log.debug("Building layer")
+    local layer_ud = ocisys.layerBuild(entries, options)
+    return enrichLayer({ _ud = layer_ud })
+end
+
+layerFromTar = function(path, options)
+    log.debug("Importing tarball", { path = path })
+    local layer_ud = ocisys.layerFromTar(path, options)
+    return enrichLayer({ _ud = layer_ud })
+end
+
+layerFromZip = function(path, options)
+    log.debug("Importing zip archive", { path = path })
+    local layer_ud = ocisys.layerFromZip(path, options)
+    return enrichLayer({ _ud = layer_ud })
 end
 
 pull = function(name)
@@ -116,6 +338,32 @@ pull = function(name)
     return enrichImage({ _ud = image_ud })
 end
 
+pullIndex = function(name, filter)
+    local res = {}
+    for _, image_ud in ipairs(ocisys.imagePullIndex(name)) do
+        res[#res + 1] = enrichImage({ _ud = image_ud })
+    end
+    local images = enrichList(res)
+    if filter then
+        images = images:select(filter)
+    end
+    return images
+end
+
+pushIndex = function(name, images, options)
+    local uds = {}
+    for _, image in ipairs(images) do
+        uds[#uds + 1] = image._ud
+    end
+    log.debug("Pushing index", { name = name, images = #uds })
+    ocisys.imagePushIndex(name, uds, options)
+end
+
+scratch = function(platform)
+    local image_ud = ocisys.imageScratch(platform)
+    return enrichImage({ _ud = image_ud })
+end
+
 shell = function(bin, args)
     local shell_cmd = bin or "/bin/sh"
     local shell_args = args or "-c"
@@ -147,9 +395,19 @@ applyCached = function(to_image, cache_url, cache_key, buildlayers)
     if found then
         log.debug("cache hit for " .. cache_url .. ":" .. cache_key)
         local layers = image:layers()
+        -- The cached history entries are kept when they describe the layers
+        local history = {}
+        for _, h in ipairs(image:history()) do
+            if not h.empty_layer then
+                history[#history + 1] = h
+            end
+        end
+        if #history ~= #layers then
+            history = {}
+        end
         for i = 1, #layers, 1 do
             log.debug("appending cached layer " .. layers[i]:__tostring())
-            to_image:append(layers[i])
+            to_image:append(layers[i], history[i])
         end
     else
         log.debug("cache miss for " .. cache_url .. ":" .. cache_key)
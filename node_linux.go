@@ -0,0 +1,48 @@
+//go:build linux
+// +build linux
+
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"archive/tar"
+	"os"
+	"syscall"
+)
+
+// makeNode creates the device node or named pipe described by th
+func makeNode(target string, th *tar.Header) error {
+	mode := uint32(th.Mode & 07777)
+	switch th.Typeflag {
+	case tar.TypeChar:
+		mode |= syscall.S_IFCHR
+	case tar.TypeBlock:
+		mode |= syscall.S_IFBLK
+	case tar.TypeFifo:
+		mode |= syscall.S_IFIFO
+	}
+	major := uint64(th.Devmajor)
+	minor := uint64(th.Devminor)
+	dev := (minor & 0xff) | ((major & 0xfff) << 8) | ((minor &^ 0xff) << 12) | ((major &^ 0xfff) << 32)
+	err := syscall.Mknod(target, mode, int(dev))
+	if err != nil {
+		return &os.PathError{Op: "mknod", Path: target, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+)
+
+// PermissionRule changes the mode and/or owner of the snapshot entries matching a glob
+type PermissionRule struct {
+	// Match is a path.Match pattern on the entry path relative to the snapshot root
+	Match string `json:"match"`
+	Mode  string `json:"mode"`
+	Uid   *int   `json:"uid"`
+	Gid   *int   `json:"gid"`
+}
+
+func parseMode(mode string) (int64, error) {
+	res, err := strconv.ParseInt(mode, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid mode %q, expected an octal string like \"0644\"", mode)
+	}
+	return res, nil
+}
+
+// headerMode converts a host file mode to the tar permission bits
+func headerMode(m os.FileMode) int64 {
+	mode := int64(m.Perm())
+	if m&os.ModeSetuid != 0 {
+		mode |= 04000
+	}
+	if m&os.ModeSetgid != 0 {
+		mode |= 02000
+	}
+	if m&os.ModeSticky != 0 {
+		mode |= 01000
+	}
+	return mode
+}
+
+func (o *SnapshotOptions) validate() error {
+	if o == nil {
+		return nil
+	}
+	for _, mode := range []string{o.FileMode, o.DirMode} {
+		if mode != "" {
+			if _, err := parseMode(mode); err != nil {
+				return err
+			}
+		}
+	}
+	for _, rule := range o.Rules {
+		if _, err := path.Match(rule.Match, ""); err != nil {
+			return fmt.Errorf("invalid rule pattern %q: %v", rule.Match, err)
+		}
+		if rule.Mode != "" {
+			if _, err := parseMode(rule.Mode); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyPermissions sets the owner and mode of th, rel being the slash separated path relative to the snapshot root
+func (o *SnapshotOptions) applyPermissions(th *tar.Header, rel string) {
+	if o == nil {
+		return
+	}
+	th.Uid = o.Uid
+	th.Gid = o.Gid
+	th.Uname = o.Uname
+	th.Gname = o.Gname
+	switch {
+	case th.Typeflag == tar.TypeDir && o.DirMode != "":
+		th.Mode, _ = parseMode(o.DirMode)
+	case th.Typeflag == tar.TypeReg && o.FileMode != "":
+		th.Mode, _ = parseMode(o.FileMode)
+	}
+	for _, rule := range o.Rules {
+		if ok, _ := path.Match(rule.Match, rel); !ok {
+			continue
+		}
+		if rule.Mode != "" {
+			th.Mode, _ = parseMode(rule.Mode)
+		}
+		if rule.Uid != nil {
+			th.Uid = *rule.Uid
+		}
+		if rule.Gid != nil {
+			th.Gid = *rule.Gid
+		}
+	}
+}
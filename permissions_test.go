@@ -0,0 +1,120 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"archive/tar"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// snapshotHeaders returns the entries of snapshot by name
+func snapshotHeaders(snapshot *Snapshot) map[string]*tar.Header {
+	res := make(map[string]*tar.Header)
+	for _, th := range snapshot.Files {
+		res[th.Name] = th
+	}
+	return res
+}
+
+func TestSnapshotHostModes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-permissions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTree(t, dir, map[string]string{"bin/run": "#!/bin/sh", "secret": "s"})
+	for name, mode := range map[string]os.FileMode{"bin/run": 0755, "secret": 0600, "bin": 0750} {
+		err = os.Chmod(filepath.Join(dir, filepath.FromSlash(name)), mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshot, err := NewSnapshot(dir, &SnapshotOptions{Dest: "/app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := snapshotHeaders(snapshot)
+	for name, mode := range map[string]int64{"app/bin/run": 0755, "app/secret": 0600, "app/bin": 0750} {
+		th, ok := headers[name]
+		if !ok {
+			t.Fatalf("missing entry %s in %v", name, headers)
+		}
+		if th.Mode != mode || th.Uid != 0 || th.Gid != 0 {
+			t.Errorf("expected %s to be root owned with mode %o, got %o %d:%d", name, mode, th.Mode, th.Uid, th.Gid)
+		}
+	}
+}
+
+func TestSnapshotPermissionOverrides(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-permissions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTree(t, dir, map[string]string{"bin/run": "#!/bin/sh", "data/a.txt": "a"})
+	uid := 0
+	snapshot, err := NewSnapshot(dir, &SnapshotOptions{
+		Dest:     "/app",
+		Uid:      1000,
+		Gid:      1000,
+		Uname:    "app",
+		FileMode: "0640",
+		DirMode:  "0750",
+		Rules: []PermissionRule{
+			{Match: "bin/*", Mode: "0755", Uid: &uid},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := snapshotHeaders(snapshot)
+	expected := map[string][2]int64{
+		"app/bin/run":    {0755, 0},
+		"app/bin":        {0750, 1000},
+		"app/data":       {0750, 1000},
+		"app/data/a.txt": {0640, 1000},
+	}
+	for name, values := range expected {
+		th, ok := headers[name]
+		if !ok {
+			t.Fatalf("missing entry %s", name)
+		}
+		if th.Mode != values[0] || int64(th.Uid) != values[1] || th.Gid != 1000 {
+			t.Errorf("expected %s to have mode %o and uid %d, got %o and %d:%d", name, values[0], values[1], th.Mode, th.Uid, th.Gid)
+		}
+	}
+	if th := headers["app/data/a.txt"]; th.Uname != "app" {
+		t.Errorf("expected the app user name, got %q", th.Uname)
+	}
+}
+
+func TestSnapshotPermissionValidation(t *testing.T) {
+	for _, options := range []*SnapshotOptions{
+		{FileMode: "rw-r--r--"},
+		{DirMode: "0999"},
+		{Rules: []PermissionRule{{Match: "[", Mode: "0644"}}},
+		{Rules: []PermissionRule{{Match: "*", Mode: "u+x"}}},
+	} {
+		if _, err := NewSnapshot(os.TempDir(), options); err == nil {
+			t.Errorf("expected %+v to be rejected", options)
+		}
+	}
+}
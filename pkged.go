@@ -9,4 +9,4 @@ import (
 	"github.com/markbates/pkger/pkging/mem"
 )
 
-var _ = pkger.Apply(mem.UnmarshalEmbed([]byte(`1f8b08000000000000ffec7b6d73e2b896ff57e9f2db7fd23626743754dd17816e8c13c29d900e36fef7d4946c0b5b41b27c2d193053f3ddb7243f60c0103277776b778b17c196f43bd2d179d29115fda9a0684199d2fb53a11ec2948bb7ef28517acaf7deaf5f7142dfa0c7d9af5f45eb8d62929826fc37c043a5a754b5134060bdfc9d7a4a4f516e949f200920cfdfa754f47faae327c0bd50e9fd7fe5b3f2fb8df2c201864a8f27292c0a5308188d949e1251fe09458c038ca1ffc94df927b002080317c34f28fae4a608fb9f3ce08550b9510c3a441832d1afacfa1c50e546410404c56bbc0ca09fbf72908897dfcb494a2a9078215a41958344b9513c4ae20432a6065b148b7292c59caa2c047ae78b72a3c0c8a33e8a0235849b7af14db07ea3c024a109536e940511530e100f53f7b34789ea536f0993f2e16104a34344ca388ad480de86290111dac2fdf680d20043d1eed1880314c1248101623cc9d47819a89150d1872856ad8fe2551f402267fa313248629e7d988aa41cf00fcf494d20a17f838c673164fb5404244b1770c8040626671bc5afb00402893040616f547417031eaa0b84a178516e14c6131405a285230285317e87718325bae94276e2665cb25559e602e7423932d572622a468c171570c32b2bde9933c87bcc0b1e8a4339b7a2ecd71b7d067605e8ed177dbdd369758f2a541471984400abd05f83c46787308c51cc91b7ab0909a8952af204447eca116e6862a9cb31dc3510bfb32b08ba5ac9bbab15ea1360216835f8f8aedc69e9b5f2c1901cd7e4b4e968ddfd921a2f513d44d4a3056051ab5e7601835feef66a500492ec82805396636976a7e3cf13f212cae8820b1f58a348dad6d976e917418afc7d6065667efd1526890f17ac31e67918e57f345aa0e00248f1104e73113a813e8c3802f892f19bfcbc0cce221e2037e58846aa8f02c8388327e2781d9ac0054c60e435737b002d820e8891109a47fd1354f271bb9bdb6d08710c13d6bc76bc4bf48e8c8a0788d13bcde7c47700525dbc44d487abcbd095355d06872b18f10b195920cc61722158e60e9741094dcf6aa2068d205fd3647919b8b492cbd08c270ca353d677845e83847c00aa26692457aa8b482e47ae60c2108d2e54ca8ae2f47ccfefbac5d918553c44c80b29813e6ab6c23c7f88a0279c99a911681ef000c644ed095b3d8072ccce84c980dea6113aeae9ddc4d14d170b80a91ac2e4a82da06a9c502e30f9cb210003b1bc7c04d210229a312a888eb2c2469c9f264008e822b0b041c601893f96028294871fcc6c77990a3cf2d68fd1aa6b80f8c73ac0343812f275877011590c12b114fe77ed2c72329527206262dff9e10e184f20201fdfd180c405f8e3d36cf4df77a956ad2257af912d69c468c2615464953e5db35b0e138222806f19fc572ad2a67f67d755b91101f1f97e76500e9690466a23348431f84feaa639ed68de365eb4b584c485fe0736a1efe318f7e9017f34167b8a42c74ce82dcf84cfa264ce74cb62e8a9e287dd06f4a3f8a35823ccaadacfd4ea194ad298c14804c024958d721df84c9340dd8844abb64da4deb211112774931d36b02cf2c4904142d3f8b891a98505cb26e10f9f6b88004672212a5c05c488a949eca98c039e9e2011ed620770b6394f0e56280f8fcd28f18369701a504ae434a2e233042c2c1eaa97786db105ae04ea661c021cd4abbc38ad171784b33cc6555511e43c013241adea2893baad57c554c6aadaf67f9f24810b0c3d8e11dfab66280a305c6014847ba3b28c790063156ea007a35553d34e9fa79aeaa9f8490ccb98bfcf3a878ce7fa90fb6c4455448be8985793fc639078a82e0a76af32b993efc5570c9267d4e2a19214732416afb2e25f29e5d08f131471e0cadd722437acc2c443cee3daabfc29d5b057593056d5d56c45d471b8e165d627ca6982cb6f5b9449e9165fb91a3e76152a936f01dcc4d58bf0350e36a25c6d2f8ab79df7024e89fc4c74d45288efa89e6582adc2fe184f3c2a15bffbe6265cbc78ecba2ff4597e92bb51d208151bf3e24d4df9a2f565bffc4d16195808dc0a463e4dd4bd88517e5f0b8117025dbb0c15539cb5da5ae71db47c0867bc14577e733a074e93152cbfd59dc1854b7f711e71fc99ee0cf89d190b93f32326fe08642cdf9b9f0256361da4d291dec595ebc039a0ae8631f0966750c88fc08966b16ce411b2a955f896caa097265075918f92f4a4b42454a6900b2a37f02741a58d8a0e2fc145a2bfdf6f949f90f1ea38254a31ceabaab392bcea89fa82c9de9fcae119d113405179a273f688c9a04fd43fd1fceb57403f13ea4bd82cff46a0f494d6e7565bf9ebafbf6e9445ce5f799ed553710ad4fcfd0f1421fe19a74034a36841c5d3871c202c29a2fa21d60e7ca330b167eeb5bf74bfde2844387eefaefd4dbefe2123424fd1355dbbd53ab7dad79fad56efaed3d3da9ff52f9d8ea675ff9fa6f734e1dd88fde18b692f006650461531a890e73dcfbf000ac6dbfa8d3248a0dccefe949dffa98ce9fa3be0302fb63a9ad66e6bddae76a38c5010ee5adadab7564bfbaafd75a38c01e3f79e07196bece3eb37fd6bb7fded5bb7b10b5d2fbbb01254b6fc8d1ec4d45ed0160a80d2d3761563bacec5293516c2e4fca9e3af5fb91af68e1e77878e79e3b913c7dde9626ea3c5e162a188fdd3c5fa99618e3e30f0fc386667ffffc35da2587bc5e0cba0e1c8b6d13ffeba517cc081d2535ca3fb36b7d6816b7423f3bb167864b6f12d9c392f01927568f95862e6a47bf79bfd803d7b167be4959a64d39a5b0f2b3830e34140f9f8a5ff34b731f602fab8eb77d871ec07ee0feebbe688770741cc5c7d12ba833e76a38778ae87390dbaef9a86d372c9440356377d343621b466d9e3330dccc17de0e933cdb566dadc9e8673b2c165fb78d067929eb4b40a4b669a6f3fa46681199059eadc0b9e4a5e3a0b5bc7a963cceec49c9d6896cedbd3d8d5efa89dad03a0cf3aa7fa72eda92bdaede718bbe4f97140cafae714581de26607f3184d29b09fe201ba0fcc413f73ec69cb237781ab4f62af8db7e382fe45d2861a30f0768cbb2d67b07c74acce52c8ab9cdf187717bed1ddfaa387d8255ef0db4b9ff856e7cd37f0ca4539ed31dfdd37604f3a5ed6c9fbc113cd2338751ac7a28fe3172d78d53ba1379a50b7fd5ce9d11375464b13f2826d4dcc7b35d787cc3466a947f01b184ed2b9dddf02a3ab1dca54d20e968f822f7334c1bed1c2be31d4e6d603735ec2ad4b86ebf1a02f9faefd7c242b497f5fc83b92e3fc3d1d8e0ada214f1c1bfba55d94731b93e1f6a7b411b351b6e341bfedb61f9229c1cc7929e6636c564ed6593ad643cbc90234d567a9633f84be21645cd82632cb3995b65fe842c85ed84ba5232983677b52f8445d47ebc0d7bb19d09cd8355e8f64e4589d0c58135af04f61d65ff8d6b398476dac7ef7f1f9488e4b6039e45086be11c65eb60edcf6943af611ff924fbb3d5b0abaca96f33e7f88fe0abb4f05663ce8af7c23c41eea089e4ef25eb3bb3affbbb1465a7cac33218fd9b699ffd2e61eb03f9a652eeaafe63ade42fb2995f43f72da3aaf0df259d8ede9ca6b4f336075a2bf35cef0accf89b8f9e05ac3c879ae62632ccb5693bfed624be57b3f309f5b3e7e348ae7c99899b78f6bfd1fc78b1c53ca1958add0d1677f3366e67d1dc7ccb2fe39f546b32d189c8d99952c0b5e9e7d7b421b7556eb77ae6f562e793de5c7e9dc6a5572aad63749332bedee70dcefaed14d9d977d9f74c99057b160a7ff726dcbf5fa127e3587a5af4f98ab77f0a13dd7e4fce6ea1d02ac0fc7f75ca7af3ef67f4c562e71e2667b8b2bdd8fa3490ddba80336d7bba9437054c9aa5ab77259bfea33edb9c4d47dde28fb6d18b758f3bcf36be57eac8c9e9a743272ece953d15ef8e25e8c29fbc81c5bd27f5d14f37048373387df9869ac0360dd05c0ee87c07ed8967985972d03c7f896eb7550c6ab896bb61fb097dd27e6f7a12ffde04cdc95facdf990f679602382a706d90cd79e314b9d669f60e6415e74a88f67bbbf167d96fcd46452d19eb4877f2fbfc8fb99bd9f5f4cf5cd6a4e86cc5bd3c75a1cc9d7f922fe3b06ce6afe198dc914cfa359f43878183956077b64a8c9783caae51d0393992316085aa91bf1dce9e082b5eb7e6fdcdd1c8f739cc711abdbc6d15a558c595b6ffb2dd7581fcab4310e15b67d2a0e3dfbd6863d1a9dd0b54ee7012f12fb5ab7c7423f47790075ac0d3b8ce97382d3f1a01f7ac4df1ef2e7e921768dcd62aeb7a40c05d634ba9939305792ae3da1667082c67e8872bf1f664efb2970db6660a2d65b89976d629f90cf6b3b26b33bc77a3a94db9b5bf9f8fdbe1f14f399da219e67e1161833e60ebb02cfcc51de66eb726c661a13ee9479a921e370c14307fb64782c13bdb52c6591d3ecf23639ce8f993eb7d6b42e1f91d7163c48598e07fd1aef8d71615be53864161ec50143e62cffacdb4d832f668e355cee634b7dfb19b0a7f8706e8ec8695fa54d31d39886bef1e3f49ea5e8a3deff78d05f8a3c63b71687a1a707877a2b3027f9a673b1b697e3177298dbfdb53bc2dfe796d0dff3515c725bdddcb65fc41a3e7c03c66ce1db0f4cc85ad2ccba89632f99693cb480b55916fbc023bbaef99dc8b356bed5d98f116412cef5104ff59956fad799f10ee34ec1a3d0e741de366241198feabebfa8f6189854fc8ca6d4b1eeeab187b9ba27f232e45b1e350b1e4d238cfd419fe4fe759f8e51c55bcb23eb608cee02f38d227370979a464e63eb1cc372ccc1c935389fc7cfb8aca3fb3ee1ac3cd48fc55c9e5ed681a997ebe93a787ae92fdd750ddbc87fb1fee5fba7b752e76eb12f2be6528c3d71c14cfb62b7ba9adb2ed79d20dee3271f6367233fe3d01b49bfa3a51d583af6f7e7b08b058eb5d93a7b6dfbfbbd5cffaf816be1ad97fd97c81bf916664e9597047b714fe631c632f8ede57e331edcbf95731a0fee37a6315dd5b18dfc17f9825c4b8b31cc8129f9adcbe79ffbb9017ddce33bffcb6df7f54bd967954bb5786c37e07da3bbb89ca6162b9bec269a843ea9d94c69a72fe69ebccaf1f27d47b017878b7c08bbd1bc3b27ddbb3111b9f42cf551100f885c336af5b24c1f9ffff10f79f095c088efbec2edbebf29e5375078bd7a71bd7af17ffc1fabae572fae572fae572fae572fae572fae572fae572fae572fae572fae572fae572fae3b84ebd58bebd58bebd58bebd58bebd58bebd58bebd58bebd58bebd58bebd58bebd58bff5d572ffe030000ffff010000ffffee1a587e4b4c0000`)))
+var _ = pkger.Apply(mem.UnmarshalEmbed([]byte(`1f8b08000000000000ffec7d5f73e2b8f2e877e1393380093343aaee4360066392b01b32c1c6bfda9a926d613bc8968f6503666bbffbad9665e3bf84cc3de754ddfaf1308b25b55aad56abd5ddeaacfeeeb8fe86b2cedddf1d6aba8446f0f5dd0d3b779d6e4869d4f5a81513dcb9e9285e40c3e84f14399dbb0cf6a6b3401e2e96bf53b373d7e9dc747ea2d0c651fabda434aa237c4291e974eefea7f3b9f3d74de725420477ee3688302c4a4b8c18f55314329dba04330047be4f2314b9d4679f6ddab9e9a0d074dc1d4e0b46ec120b8769c144a623ea4dea052166cca57e56e16f5c3bfb0e92f46b2370bab64f43d1d5f5909d7dfa163ea49f04253814d0840a443eb5f02fe2fab1000a70e8b97c5001196c6d6ca59f2136101378d9bf62c41cf11da1e857ecbb024584c464623f40e6567cb37cec3d22a272efb811a671244a34dc5aaee87b4051149e08fb2b5bce94a129ffba110a4fdcec1edd80b373e302362389302bb0b16ba7ed66980411ed320749c32f9d9b0ef64d6ab9bedd75f0a1587c8395bce9e030a421e0d978202db61b39b1f1d9a45ed7a2e61687d98f495cec57216216b97ed7a69f9cd843be7bc4e5769b529b606837a91f21d7c761886d974561d20db67617c591e37fac8b8fbc0f0eb2eb7f14be6b21ecd10f12b6eb77b11744c9877ba5201fee465042e3e8c3dd3cbec53fdc2b8e50f4711a43ecd1dfe816250166e55e5b82621650167573713fb2c86a030ac45e28347a28dc1a28c20c88c3e1d946f82f6c110f7b00473fc7060e3fd3d0ee1e116c31beff5cda75691cb904940c8eba71085f1408a7ac8b0fd804e502ca39fde96e5c824539c41b824d58bd10dbf80038c3d88f5c2edb8c86d0c2a2d0a4fe2efd727d1b10b3c407ac2c612622301ceff2d74de73b0e3eae38088dadcfe97a88a5e1dc8d23dcf570842c142100f382a296d99054126a6a275bcd2e7159242af021ca35d24935a174f8b460ba8183c353d92a365a0c9d0ad8b49c52a9d46849c3617f54a820c40d22d73cd56cdc80f56f7ba70a676b6d0a250f15809d608b4f25d78f70e823d23568e8fa766b43d730dc33adacb1d1a43e8b901f89e5af36633f0a69907477fdcfbdcfbd0680dabcaa2d658637b5766dd33b07415c740e83e1da1eb5ce00980e36b767daadd0b0cf349757bea999a173ed55d96880d8a3d0621f01eb6e5c4ccecdb92c5df5e692b8d59a3d727e4e1ed9e2734be6bb2cc2e7064801ba1b174567a0c2b344e436c61980c1f9e6615f3a07101b11c1670022c2ce2280f63314706bb4bdd9c201eb829d45430b87efc09941fc0e844d2d6cc467049d43b5a80101e22076662b509f240dadae179086ea10f94d020cd5e25cab36b184953b79d6b05028cb6c4544cb1d43f3b6502876630eea974a25112b4b545580aaf2129182da8a08ab31ac047018f60abb1f4add60eb168de6a2fd8c98df2f96c1751848d59a2fb7a51ad747617281515e2ce744b736f0d286209b9d07a141f40ec4de0d710de28de54779b961579a6e80bd923f4190dde456e4669fd565110aede327e6a38039348a70d8c569dd6f74e9724f46086edd8d31899bfee34ee60520e207acb68ba0436c613f721161978037d8b802d0025bd8356270a7bb966b6316311cbd0f1ae20d0eb16fe24b4085c19d3a75dc0feb9a0e2204fb764b7ffef3e934cb4f0e26010e59b363f86ea777b8257e50e0bed37c8e9115a0ae41b62eb5f0ee32e85ce82e03c73bec471712b2714984c30b8179ace332508fc67e7419a88fa33d0db7970167f27219348b42465c135f08bd47a1f701d0eec951baa0cbe5903b1cf2a0d085d094c41efe70bca40481c3d0c29bb352000eb1433d6cb9612358ea7cfbd8846dcdba3e8a2e0163d03962978046849d519836fd14fb6ec43e1a1532e2cd0611da7570586bb369370869448d78937e5c1e3ec84fb2d4d2f868afcca375a9ff1bbd1b0fae8b7ad6631517750bb185cce8b73ad6b6f2e5fdba7be4fecea03b1cba9bdf19b51ee6f90f44157f6be9612c426df6b11ed700e6ff4701cc008560d1fdb7029f69bcb41b85c8677017f061042c0a31f23edc2d42a191c6133fd8ef9240ed7bed7944f11d28f63e8c136f36bd77a172d56206315cb55dde01bc8e106ffe9f42d325a013eac34178f6ff9178763e8c870276216884b698fadd46500707e8df84a6d918aea24b03f297c074b16760eb224811de7f178e4516add2e746a6830971603b365a6834c07ebe711880a5bedc59286eeb7f620136bbf01ff6c9a61f85af1d17a01a72a7bc50cfdc300e18f6bb84da615c69dc19288a185c237c620171a36ef962a178258222eab966a5d28b49e4e2b00a9b5e9f546aba600ee206d09310a51001a5e41c9449093d8b061fdca8a1fd880293a6962825c8b779c3a1ebe3e8d4156c66d6041184f490541b28d82052732df7b61de45b04872d10a713b5b5b138a97476c83433f7afa15706ded2fcb667ad2d3594700b05d1163ba471506f4c2fbf9051c3c812d6855bf4ce4d47283bf8e99aa1c9e366f98c90e1968a0cf9c5b2e1b2f4eeec5493441811bb5a95456df34ad341a683be8948e4a99aee70886cdc0d2393ee4a2d415c2c663778c48d70a9de8bc4a55d5e6553d831e59a2cfa5bad62e53a7c0870e87a6950a5504f4b705e852b3e8ea2109925ba28e31bbf581550424ae590c2ac426cd2b0c4942a2e7165599dbaf0c64f5aa0d6626662526b81ede850ba6d6ab31b71d9669799c86f6a12275b437de434d5074148375d820c4c9a9a59d2884ddcbc7679de461180a10d0e5d5aaa727d9be00d716da7b492a78bdd6215dcf056992bae7b4be508b332364111ec39ecef9a9ac49e2bd60bbb27af02ac849684934b13d8a226de95b667ecc3641d8cc4ee1297e11b56b9147723de9ea225d4ce5556e7a623564b2c0efc74d39b53f11965add92545fedde5c478695c077ed2b3062cf5ace25f318db01584ae1f2183eb519f075041a13b5114143ef97fb27d53aa1493c8eb0ac4d7eaba8899aedbd80225a9b5c5a45eeaa73537b3cd4eb4f9387233ba21149f45465a130f28ebc60c871f4b41e0b9082065113a94521232f1376d5a2865fa2c2b677ce4d13f766126836f9e74871058c86bc01043b2b30c879b4eecbb26b50a5fdd38daf4bf94cbdfd2e2bfe2140ec4b473d3d961dfa261b77414893b9ef440907a9741059424fd416ff80e34470db78697c2655749678073b1c8aee22f817d875e901dcb675dcb671e662c8d32b701e6fbc28e2376095c66179d0394ba4e80cced1928d7f2514b335813e99d6b532b6c922ec3661ce2aee15a6e986638b682729f7b4343ef1c50266a80f012383fc5b7c7680b09953f318b8a5993a949fd0b746f2953b25093798b2ef54bb54152286e58a190a649162bc04728962161b2504eb3260b15850cc9426d9a1b59a84813240b15110a0ba53437b258c1cac3409664b1982647166a0a199279ade0e285a992b9af2ddce7ea9d727e7599a646d6ae2adf0f81fc97426cbf1b2bfbbda85776a5564b73a3ecec417252f7b9926fd1e77fdd749ed2a4e3bbbf3bd504e627e4fa9dbb288cf14d73deb34c9fa855a9eedaf4739a8324d3557a95d3b9ebf43ff7bf75fef9e79f9bce26cd55ce72aaefba2446ddf4fb97ebbbd167122368e686d1dddf1d0b47c8253c0bdb2f26549f806f3a0c125eeffafdfeb077d3f1e074babb95d2cf5f7c9a771da9277df9d4ef7dea7ffbd9efddf50677b75f3f0fbef66e87df06d2571dd8cb7e41e820cbb206b313d2bdf1ae73f765d8936e6f3a8a4ff920b7fd61ffa6b320aebfeddcf5390371e76e30e87ffb76d37975adce5defa6238b5fedd7af00593dfebd842b4ff87829d23b26db94fedbdee80b14b9837b0723de47ae07137fc166e7aeff75240dfa5fbf495f6f3a0b0635836fd2edb03f1cddfe73d3797a07349bea3f379dc9e5a0daaf5fb11f336c75eefea777d3bbe9fdc5d710f2041b73e16131abf9f0a74cf8b4f15c1afc29f5dd8f09b93cf33d85ce95515a4c5331d3ef92c63f55557afc37b782b00761db6ded863f1b68dc17ffdc747832e85dc790476f6b756f1bf2c857bef76cd35b1d2c9524fa8bedf23a77fb90c1acbdd1ed9fda9c98da2a30bd57aa7887fe5a9deff0440926368d1e5fc63f756dd9d3b5f951992d1d24bddae660992075e89bc9de5ebf8c63a4ee6dcb9b324b7db591f664afd55b5bd7e68931506cd39bf690bc4a94d99242bd253b4499cca18fabcc163dd323b1be0f98212d1c63327e43f2ea0df575c798ad08d0aefbab783d580686744b4d69d5b3e455f23819c76bb54f1e276369ad1efafacbf64199dcdb8a4c3c6596f71d29f230302663183f9eb8f7368799dc67b4e5f894c96dac4c942f8a3cdce5fd652bb0bc55acc8face74ef5d0e335beeb2b93fc843c7505f83c7c9fdf1e19972dcba3adca6e38c135d5bf64d0fe63b3a5ab379607826153c0a26de2ad6efe9c3e34bcffe294f87baa63c4cbcc36e2d4d99221f1cacae12437bb6ff7c197fdd3cd37cad747598207541451f68f72c75f866c96467b80e5b6b8498aee0c56c412cb94f2c79da5bab73a6bf64ed2017d07f1e59930c764eacd92a31dc710a6307c4f09e81a68c9658eb8f02c35b123cb91f29b2de37bc450fa9a3f861fa8d297244f04b0b2eafdf53a588e055ef81f3c70ef2fa47587bef5b751e3d24939ca7a6b7ea59da3c56e4d11bd216433319a6fdb7bc2fb564273093e1c6529f73be9ee637dc6883d39a55e529ed5ba37bb796c8116b4fb1c0f3335fbf86b1266e2f52a68b78ad8d8f481ef5f2759cf1bac8027ecda2d1c4ce659c18fe3c584bce4fc39bee4dc9d959559ef2bef78190a5a3ae2d235d5b3a963c750df9959ad2d031277b9bffcafd5e8d5769ff8cdf4718e737d750f45d1a485a0db56721177e36b7a163f652796de6eddeb6a451827a7a60c8af4c91477b2eabfe533637664866accb2bd7524daa6c2d6278ab64ad2d61bf083955dc6c7e485ddbd68cecf51767600ce6e1d2234c7fd9da7ffeecd98abf74d6de81286e7dbf1b833197553381b5b076a617c9483d90224c814e9047d04f42a7f0bdca6531db87422e400e00369717be1e3fa647b13f8bf2c28ae33e4ec63b73067c1a36c8f92a363df2867ea47c7d98315b1bacb6c0dfe2b89b976d6d7d759978ba5b95a52545da13f01ef8923c3c97e7c2699e8efa3a97c17c8f719c4b8e2fdd8f8f0460f6b63158525d5362a0a995f6c27e28d27f1a6b3c7aa8cb9207bc319346fa83aa5e35a445600ec8f131edcfd7d34c8ab4d6f983e4e9114d7e0bbfb2d61654c08ab9d7e83f82aefd4dfa7fa67dcfd3aff5473d6320ceccdf1de78c2e83f3e845ed3bbaf49a9f398897574d7aaca0b3739d3637d4a9afbf3841fadb7a1689f622fe9aee49613239f148b4562df29b7a2cc5a5d6cea2bcfed11ff74dc93e7b16e5bc14b4bcce564734695cb3135e6fc10c6948daf4a3e14da393fd92d900699f7cdf57c67d960e3bc37b2dae2153843d525dffcc6610eb4a7132cef4d69b218f627d55d98f620ce0f35a1ac5ba473e7c6e8a359de9daf2bb210d3da436cbdb69ed87c7026ce31a3cca8b9de1e9819e8d7bb207525eaf16c4fa91c114d7e4f026f0b68d3b35fc75f3989371a86b5b96d984ad7220ad7a4b7528893dc8f543de575e505d5d84abd4f6a38a0f74ae62cb5584ace6b673d0b406967a118dd9beafaec1cac8c72beb7138cf9bc6039b78a90e7ba64ff68644626bd2769eac626b361f9af22830fce7b6f1c177981afe32c1da7887d461afc223526a6ba1e759ea6fdbf6cf5aea6fcfad0bf4ad8cf966b4cba00676852e93567b06dace8db7023b425a066685df69bfe6f9adb40531dbceedd9eaa86bca39feae4c699554e758f4a31e26f36306a7b87b5bf1394ef771023a6f953cbc34e9add5ad298f8efa6abc33fde736d9db1b8379af8d1f4bcdd91b83057995478955d2937b7b2d39642d456aeabfd9ae5e825598329973dc40afc9ebb64d6be6e8f25235a443df505fdb6894447b1b0fc7051c659d3ad377c66c15e94dfce1fbeae0acbd156b9315d863751fb5496fa5782ed71f625cb736469b5cffb4e4d1fe5522bee14d5be93545fb39f97e057f6aba087469e818efaca95983e7eb7a44aa15afd53dc85ff67dd27de04ffdf891c71894d9929883671e5730fd55acccacc092ed348e3003de1cc0b6f520bea0bfe43643659d1cb2560f3d2478d9ba5ef2ca31204ec1e7c13279097477dcc3da983cc8653c8f3ea72de0b186c93cf3f35d4e8b7afb90f916ca644c7575ca2cd949e31bc9b0a76b8b1ed081937bf7b9d7fff1b85a28cbd7c313c87b858ed89257476b321e097a389fda65a94c6345a62a7434edfbd4ef78c78ecd6cc0d417f79f4a7e90903d38ff9f447bf9fc2fe34874ed298b75707ee9de2851785c01ecc35b1b69630769f3e383888d98c9d6d6e56f45fe26bab63094c19c98c97da87c9f5ac0db4abca468fb3065d60b6afc3cd9324093e00d8f8151a42d7ac6603e54647214b2f6667a2bc792575b4d82fa2531a54582b431d7df0599147197f1ce74c70ec4d0b89f0aedc013d84ffed6d63d420c79797cbce7e3d570ebdeb46fcc9630f64ee03dae07f3c09c3d0b1b72ec18deb36dc8242ec93fc418e455ac4f5ace17c1d3c7c9698e0dfef6a9ede57ef4e7cbbd9befcf067f1bce064b1e25f805fc6d66af0773b2d696449f8e5c5e5798f7a61e3babe967733626862764c92deb1c1123c8e5fb3487fa595ba2eb23be93c0f192f72fd2d07436cc89a18e24bd791ca6ccc68e253bed7e85b78a8c814e2a7b77bfd696d44c9ac75bab4bee03b7ea7679da43751fabcaeb175d9d6ed3b84a99cf59fffad83097054dfb9c9bafcd14598ff339cb23ffd15b92b5bff21f26f3e7b5b67c4320bbc998c701400fe2249d33cc291d7f3c6ae519c4392587f3a0896f8f93b167b80df47bd3ad3ee5f6509bfd90da48cd7483ed003adfb6b80da73090f7743f82ec73bcb1d89fadb43fabcb6d6a0396799ee26ca1f987951883d5be6dbdf5c17c6769f72d71b6e9564f79ed9bdea86f4e2a74cb29ee478ffb94f6a6cd76793dd151e1b9c0db44fb6267ce886c7aa3a88d76116362ca6c91acb9cd0de7dfbe10c7cbe839ecf464b8d5d5795f4f6cf7591aedb1cae7e571fc722a1b429644cce17e548ee38bb8d8cbde3607f337a0097e1f27e36d7646ebe2fc6d5d3f18f7554f0ca957b61f05ee7c0f15f0966380755d65a9c3fdfa8cad52f243ca32b932fcb1b396222e97e959fc0ab6d6d79c1fdf7be57b098e136c4df05b60fde1b77dbe2b415b65cdb748539aeccf4457e78e29b5dae73b435e4ed6da02ee73625db3b2ef5a8cb4b2f75e52bc7cbdc5daa6722ce60ce315e2652296f3b2b7757fbe33ea72c0f41f800fee74c63d23a9b60f89d54bdb0bb64383fee4305539c87067bc3acd53c84651b66b7bc65f1c2cb53576f97e5c9d88fef24779d52e03af8369bf1a136d94696ecf6776dbd831bd57be9fb9dd0f36c164ece2176e4380cc810f1888986290c7ed7e8a3b23ff893ebc801e50ec35d84c32f1f91ee57774e34457fb3bcb5b093b21b5874c6fb537e4e99bfe9ad250dc63a63867857dd8269fc27e6bd1812b71d60bfc157ee47deb6b3a27a67c70d65246efbbf619c8ccd37bb494718a39643296ce136285c412f736ed714b02b197c412f7106d7a08a9c3adaed9399d6df2f2c2e358f35e668b97f7070159bd3df1ab1c27cc62d185f173ff30f58b57c70711a32b9e0d3c963ae33e411abb4ef78607f28392bd6d817cb9e3fd5a25899938e95db2f61428f27257b4674d6f7554a5c8aae94d775bbb23caef4260dc7d5dafafd5616c0ce02eaf551f3a8637dc41acbc7cd655d6fa751a1bdea807767545e6047d962ae6434f63a6776f0fcfe7e8ba581e3f4c67f3dae7f82ea79beb14b8ab3b64bed8cef4ccd818809fb0d819fed241ea10fc52a1975fed0a2e710680de794de30a105ff69704cf9e8bfad4063bdcf4e7812ebfda96b74a4c09d66a0ff77512d216702603eead325bec2c6dfe56f1bdf2b9bf0e968ee12d1dd36b8da5d5cfbbca1e7acee7b0fa69c9d35897a7895e89c19c3f47607f2d3d63306fb1bddef50f9e0d79da03ffdc28f9070d6391d1a6b0571ae7fcf05cd93355d95995cfa3f258dc4f16f74d63067e1fe8a3342e5498a7b079cc64bcd3ddb1c3cf14c863b8cfefb178dfc67c8b82be39c512c8d19a88fb2deda9f58e5ef0ebf878c25ff32f3398939e03ff32e59dd0ab55be6d2af1fe1c57d6b79a6b21c68e7575fad66e3f9cfcb253ec45d88a32891599643a32b30bb6c69ee6fa91f717f0391e38efc4bce05e51f70883dc1d1e3f915701ac138f8714e8a9c5f15e1a6226a5b5d5035d3d6ccd04ecb571c47dc8fbd39c4d69c57475d16b96f57d4e534673b6eea0efa10f1ef4844ee3f605f03ea30bce8dc094a7c129b6558b4965f275d4d503590fc0d6e1366562a9af45b8427c510f0c8877ba22a7c12bf0a621ce92ddad1af2ea0de250caf7139fabeb21ce29664ac5b1efcbf3960f8e05b694d703b9cb6caffd69cfdb42ee0aff00de1d87108386731c7cfd3456779a4b992f857f3229ad273f6367b72365b6e6f15dc3a6f53eadf39e36cead7a3e9ffe15e27ecd655897c2dab5f17f4ecc3e8378dd519930fb6955e0dd336da4a1294faca25f20fe18d4f4c2993bea424e5ced1c31e19efa476a6389bc35a634f90e623c9ed3f4c3628634778cd9d3c3a42887b3426e07f713dff7cb97d22ad6b5399ce3e07f1c0b794fc22f6df13b33b984bc11aebb1af34b8a6b96c577f3738be79c4c1cf04dcb73e572dc30169caf331e8fe674fee12fe0fc3e3e64b1b3746e81e1e9bb87c9bc90c335eeadd5881870af3b63767e872813c8573ce5b6cc527c8f1ed82ffc7c38222de03eb9183b3625f2a5182f6ac9eb628d765a7a2f1be897f885db791fa9872dc441323b4c712bf6b3a8afe722a4f5cffe2a30e467aac39db147889934c8c3c99ec8f24432ffe4ab92e71e15c699f5725914394f3c5eb492a7353f248d0bbe2f832f6a9fdf278a3b25c7f4e68e21f33ba8af223e096b9ec61b4ffe7d6e23707a492d4f4ae42242eca7ffbad694767afe4d3c503db2fff7f02008cc09f8e30b8a34fdfd78abd81f8296da3eabd2f890c77c6b32d8a0f3eaf733277e1773c5c67d43de57f51fcf05aed2296cab1a9da2fed9520f2ccbe1fd40ce8cb04b9be83acc1b7392327d7b3a0f2ff24fb5a43c87f23d58d54e2e8e2ff8f1f2b13bb2f7e75a3bbbb2bd91d9c2c0e75cb6c1067be073cbe64bbc130fea67a998eb1168cabefff0c5193f71407ff60a7910a7b3fab4af323a32fdb8b7349857a31d9d9d95599f165dc9fd83be3e6bbb9f6cb491339cb53882a52e8f6ab2e0f81e92fb8306314861a771dfa66e1f54e27870fe3b22aecbe725ce4f2e634063755ef69f2ff76f30ee9998e573814f34bd03d8dbd0a76c4b64b2be78839cfe75dd0e29d889151917736ccb677b951690f3fb86260d38ea7a53f87c45bd59c05fd09ba6e490067de122f59629e03b0f6a6b7d44f28a19d311e408c1fc0096c71b157704df3b53b2dd963e8ee9595c7ed7dadc077f13fe6ee0517dcae0cb3ec5ec29d63587acabf7ecf222d2335b63525eaf743e872996576f0f22f74193001ef27879db261dbb9c175590a7e3a3b792d66a8d2730dfa094339dad919f8eb3d474c8f728f28729390d9c974c29d25eb0774ff64bbe3713b867ac9e63e0bbe8afcd3af9440fdc753e9761c57a5b8379d0105703dd417e0ab9d6e19eb02e5b398f058e227ea6c81037fa918fd398479cc1b4d1ed398e29d9341b5ff0c1316763865f170ee44335dcd1ef3449e887c9f86dad2e28e863d3db03af791f0dfcfd97bdbde676d8328b930715192de872f07b477dc32b9f8b6b690a392190b391edaf33e335efefdf394f90bacee9a9f97c15fb25a5f1d54632e995fe2e27a36db04a8cc97dfce8debb7fb88afde8deda625e9b34dfb18cbb68df9ac9498ffc91e7c094fd5d8863ac20be24c6d30bf906221f12ec6e7badcd09e476426e86057b36f5dd4f3922ea9c883847b65ec5fd9eadd90977516f4c2a36c2a41c8b10f1ce2fa75c073ba8c56b4efe6d6c406ed564480c6ddcc3ab91f0295a7c5d91cb94d1a5268b138d27df97d6fa157441739978caa4800b62009345c69b9658c059fe547d79ce2f04e7edf71fac809b29df7f34c6272a778d8ec841e167af90a96d263bca24953561031f5589587f94e39ff4e1655b1bc39221ce97aed75a1bef4127e4394efd28d05ef676be8e2b286f9be315b5b84ec56ef0527955e47e600e9e60bfe53467fbc3d2e62cff3b3817f650ae6f0af1c6fb265f809f6b15fd43cb7b8dc788204fce7e7ad9db8a24e698eceda766d9acd02f7298d2f841e6f78abd5fe0d7cfa0f4f75e0f9332dd42d6c41ecff2a2c4dfc5f90b03ad7a75f8820ebea4cfe9ac6d94a3e35ad30b32f42a6cddd7fc6f9c4a34a6f19e9c97c598aaae0ea53ff9df690e7d911f4e1f9e036e8314eac7507e986cff4f07fea63484ff5d51fe57a5a7bf27ed647fb98bafcf585d9fb1ba3e63757dc6eafa8cd5f519abeb3356d767acaecf585d9fb1ba3e63757dc6eafa8cd5f519abeb3356d767acaecf585d9fb1ba3e63757dc6eafa8cd5f519abeb3356d767acaecf585d9fb1ba3e63757dc6eafa8cd5f519abeb3356d767acaecf585d9fb1ba3e63757dc6eafa8cd5f519abeb3356d767acaecf585d9fb1ba3e63757dc6ea7fd13356ff170000ffff030043e18d9b1b980000`)))
@@ -0,0 +1,243 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"reflect"
+	"strings"
+)
+
+const (
+	// RebaseMerge keeps the value set by the image on top of its old base, and takes the new base value otherwise
+	RebaseMerge = "merge"
+	// RebaseImage keeps the value of the image
+	RebaseImage = "image"
+	// RebaseBase takes the value of the new base
+	RebaseBase = "base"
+)
+
+// RebaseOptions selects how each config field is merged when rebasing, RebaseMerge being the default.
+// For env and labels, merging applies to each variable or label. When both entrypoint and cmd are merged,
+// they are merged as a pair, the image keeping both unless it left them as in its old base.
+type RebaseOptions struct {
+	Env        string `json:"env"`
+	Labels     string `json:"labels"`
+	Entrypoint string `json:"entrypoint"`
+	Cmd        string `json:"cmd"`
+	WorkingDir string `json:"workingDir"`
+	User       string `json:"user"`
+}
+
+func rebaseRule(rule string) (string, error) {
+	switch rule {
+	case "":
+		return RebaseMerge, nil
+	case RebaseMerge, RebaseImage, RebaseBase:
+		return rule, nil
+	default:
+		return "", fmt.Errorf("unknown rebase rule %q, expected %s, %s or %s", rule, RebaseMerge, RebaseImage, RebaseBase)
+	}
+}
+
+// envMap splits KEY=value variables, keeping their order
+func envMap(env []string) ([]string, map[string]string) {
+	keys := make([]string, 0, len(env))
+	values := make(map[string]string)
+	for _, variable := range env {
+		key := variable
+		value := ""
+		if sep := strings.Index(variable, "="); sep >= 0 {
+			key = variable[:sep]
+			value = variable[sep+1:]
+		}
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = value
+	}
+	return keys, values
+}
+
+// mergeMap returns the values of newBase, overridden by the ones the image set on top of oldBase
+func mergeMap(image, oldBase, newBase map[string]string, imageKeys []string) (map[string]string, []string) {
+	res := make(map[string]string)
+	keys := make([]string, 0)
+	for key, value := range newBase {
+		res[key] = value
+	}
+	for _, key := range imageKeys {
+		value := image[key]
+		if previous, ok := oldBase[key]; ok && previous == value {
+			continue
+		}
+		if _, ok := res[key]; !ok {
+			keys = append(keys, key)
+		}
+		res[key] = value
+	}
+	return res, keys
+}
+
+// mergeValue applies rule to a single config field, given as pointers to the field in each config
+func mergeValue(rule string, image, oldBase, newBase interface{}) {
+	target := reflect.ValueOf(image).Elem()
+	switch rule {
+	case RebaseBase:
+		target.Set(reflect.ValueOf(newBase).Elem())
+	case RebaseMerge:
+		if reflect.DeepEqual(target.Interface(), reflect.ValueOf(oldBase).Elem().Interface()) {
+			target.Set(reflect.ValueOf(newBase).Elem())
+		}
+	}
+}
+
+// mergeRebasedConfig returns the config of the image rebased from oldBase to newBase according to options
+func mergeRebasedConfig(image, oldBase, newBase v1.Config, options *RebaseOptions) (*v1.Config, error) {
+	if options == nil {
+		options = &RebaseOptions{}
+	}
+	res := *image.DeepCopy()
+	rules := make(map[string]string)
+	for field, rule := range map[string]string{
+		"env":        options.Env,
+		"labels":     options.Labels,
+		"entrypoint": options.Entrypoint,
+		"cmd":        options.Cmd,
+		"workingDir": options.WorkingDir,
+		"user":       options.User,
+	} {
+		checked, err := rebaseRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", field, err)
+		}
+		rules[field] = checked
+	}
+	switch rules["env"] {
+	case RebaseBase:
+		res.Env = newBase.Env
+	case RebaseMerge:
+		imageKeys, imageEnv := envMap(image.Env)
+		_, oldEnv := envMap(oldBase.Env)
+		newKeys, newEnv := envMap(newBase.Env)
+		merged, added := mergeMap(imageEnv, oldEnv, newEnv, imageKeys)
+		res.Env = make([]string, 0, len(merged))
+		for _, key := range append(newKeys, added...) {
+			res.Env = append(res.Env, key+"="+merged[key])
+		}
+	}
+	switch rules["labels"] {
+	case RebaseBase:
+		res.Labels = newBase.Labels
+	case RebaseMerge:
+		keys := make([]string, 0, len(image.Labels))
+		for key := range image.Labels {
+			keys = append(keys, key)
+		}
+		res.Labels, _ = mergeMap(image.Labels, oldBase.Labels, newBase.Labels, keys)
+	}
+	// Commands are merged as a whole, as the image entrypoint and cmd only make sense together
+	if rules["entrypoint"] == RebaseMerge && rules["cmd"] == RebaseMerge {
+		command := [][]string{res.Entrypoint, res.Cmd}
+		oldCommand := [][]string{oldBase.Entrypoint, oldBase.Cmd}
+		if reflect.DeepEqual(command, oldCommand) {
+			res.Entrypoint = newBase.Entrypoint
+			res.Cmd = newBase.Cmd
+		}
+	} else {
+		mergeValue(rules["entrypoint"], &res.Entrypoint, &oldBase.Entrypoint, &newBase.Entrypoint)
+		mergeValue(rules["cmd"], &res.Cmd, &oldBase.Cmd, &newBase.Cmd)
+	}
+	mergeValue(rules["workingDir"], &res.WorkingDir, &oldBase.WorkingDir, &newBase.WorkingDir)
+	mergeValue(rules["user"], &res.User, &oldBase.User, &newBase.User)
+	for port := range newBase.ExposedPorts {
+		if res.ExposedPorts == nil {
+			res.ExposedPorts = make(map[string]struct{})
+		}
+		res.ExposedPorts[port] = struct{}{}
+	}
+	for volume := range newBase.Volumes {
+		if res.Volumes == nil {
+			res.Volumes = make(map[string]struct{})
+		}
+		res.Volumes[volume] = struct{}{}
+	}
+	return &res, nil
+}
+
+// Rebase replaces the layers of oldBase at the bottom of the image with the layers of newBase, keeping the layers above untouched.
+// The config is merged according to options, the platform being the one of newBase.
+func (i *Image) Rebase(oldBase *Image, newBase *Image, options *RebaseOptions) error {
+	configFile, err := i.img.ConfigFile()
+	if err != nil {
+		return err
+	}
+	oldConfig, err := oldBase.img.ConfigFile()
+	if err != nil {
+		return err
+	}
+	newConfig, err := newBase.img.ConfigFile()
+	if err != nil {
+		return err
+	}
+	diffIDs := configFile.RootFS.DiffIDs
+	oldDiffIDs := oldConfig.RootFS.DiffIDs
+	if len(oldDiffIDs) > len(diffIDs) {
+		return fmt.Errorf("%s is not based on %s, it has fewer layers", i, oldBase)
+	}
+	for n, diffID := range oldDiffIDs {
+		if diffIDs[n] != diffID {
+			return fmt.Errorf("%s is not based on %s, layer %d differs", i, oldBase, n)
+		}
+	}
+	layers, err := i.img.Layers()
+	if err != nil {
+		return err
+	}
+	config, err := mergeRebasedConfig(configFile.Config, oldConfig.Config, newConfig.Config, options)
+	if err != nil {
+		return err
+	}
+	rebased, err := mutate.AppendLayers(newBase.img, layers[len(oldDiffIDs):]...)
+	if err != nil {
+		return err
+	}
+	rebasedConfig, err := rebased.ConfigFile()
+	if err != nil {
+		return err
+	}
+	rebasedConfig = rebasedConfig.DeepCopy()
+	// The history of the image starts with the one of its old base when it was kept up to date
+	if len(configFile.History) > 0 && len(configFile.History) >= len(oldConfig.History) {
+		rebasedConfig.History = append(append([]v1.History{}, newConfig.History...), configFile.History[len(oldConfig.History):]...)
+	}
+	rebasedConfig.Config = *config
+	rebasedConfig.Created = configFile.Created
+	rebasedConfig.Author = configFile.Author
+	rebased, err = mutate.ConfigFile(rebased, rebasedConfig)
+	if err != nil {
+		return err
+	}
+	i.img = rebased
+	i.platform = newBase.platform
+	i.baseName = newBase.qualifiedName()
+	i.base = newBase.img
+	return i.fitMediaTypes()
+}
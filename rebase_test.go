@@ -0,0 +1,137 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"reflect"
+	"testing"
+)
+
+func TestMergeRebasedConfig(t *testing.T) {
+	oldBase := v1.Config{
+		Env:        []string{"PATH=/old", "BASE=old"},
+		Labels:     map[string]string{"base": "old", "shared": "old"},
+		Entrypoint: []string{"/old"},
+		Cmd:        []string{"old"},
+		User:       "old",
+	}
+	newBase := v1.Config{
+		Env:        []string{"PATH=/new", "BASE=new"},
+		Labels:     map[string]string{"base": "new", "shared": "new"},
+		Entrypoint: []string{"/new"},
+		Cmd:        []string{"new"},
+		User:       "new",
+	}
+	image := v1.Config{
+		Env:        []string{"PATH=/old", "BASE=image", "APP=1"},
+		Labels:     map[string]string{"base": "old", "shared": "image", "app": "1"},
+		Entrypoint: []string{"/old"},
+		Cmd:        []string{"image"},
+		User:       "image",
+	}
+	config, err := mergeRebasedConfig(image, oldBase, newBase, &RebaseOptions{User: RebaseBase})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"PATH=/new", "BASE=image", "APP=1"}; !reflect.DeepEqual(config.Env, expected) {
+		t.Errorf("expected env %v, got %v", expected, config.Env)
+	}
+	if expected := map[string]string{"base": "new", "shared": "image", "app": "1"}; !reflect.DeepEqual(config.Labels, expected) {
+		t.Errorf("expected labels %v, got %v", expected, config.Labels)
+	}
+	if !reflect.DeepEqual(config.Entrypoint, []string{"/old"}) || !reflect.DeepEqual(config.Cmd, []string{"image"}) {
+		t.Errorf("expected the image command to be kept whole, got %v %v", config.Entrypoint, config.Cmd)
+	}
+	if config.User != "new" {
+		t.Errorf("expected the new base user, got %q", config.User)
+	}
+}
+
+func TestMergeRebasedCommand(t *testing.T) {
+	oldBase := v1.Config{Entrypoint: []string{"/old"}, Cmd: []string{"old"}}
+	newBase := v1.Config{Entrypoint: []string{"/new"}, Cmd: []string{"new"}}
+	config, err := mergeRebasedConfig(oldBase, oldBase, newBase, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config.Entrypoint, []string{"/new"}) || !reflect.DeepEqual(config.Cmd, []string{"new"}) {
+		t.Errorf("expected the new base command, got %v %v", config.Entrypoint, config.Cmd)
+	}
+	image := v1.Config{Entrypoint: []string{"/old"}, Cmd: []string{"image"}}
+	config, err = mergeRebasedConfig(image, oldBase, newBase, &RebaseOptions{Entrypoint: RebaseBase})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config.Entrypoint, []string{"/new"}) || !reflect.DeepEqual(config.Cmd, []string{"image"}) {
+		t.Errorf("expected fields to follow their own rule, got %v %v", config.Entrypoint, config.Cmd)
+	}
+	_, err = mergeRebasedConfig(image, oldBase, newBase, &RebaseOptions{Cmd: "unknown"})
+	if err == nil {
+		t.Error("expected an unknown rule to fail")
+	}
+}
+
+func TestRebase(t *testing.T) {
+	oldBase := layersImage(t, buildLayer(t, "/base", "old", nil))
+	err := oldBase.SetEnv("BASE", "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newBase := layersImage(t, buildLayer(t, "/base", "new", nil), buildLayer(t, "/extra", "extra", nil))
+	err = newBase.SetEnv("BASE", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := *oldBase
+	err = image.AddLayer(buildLayer(t, "/app", "app", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = image.Rebase(oldBase, newBase, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]string{"/base": "new", "/extra": "extra", "/app": "app"} {
+		if content := readContent(t, &image, name); content != expected {
+			t.Errorf("expected %s to be %q, got %q", name, expected, content)
+		}
+	}
+	configFile, err := image.img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, variable := range configFile.Config.Env {
+		found = found || variable == "BASE=new"
+	}
+	if !found {
+		t.Errorf("expected the new base env, got %v", configFile.Config.Env)
+	}
+	layers, err := image.img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 3 {
+		t.Errorf("expected 3 layers, got %d", len(layers))
+	}
+	err = image.Rebase(oldBase, newBase, nil)
+	if err == nil {
+		t.Error("expected rebasing from a base the image is no longer on to fail")
+	}
+}
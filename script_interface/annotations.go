@@ -0,0 +1,103 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package script_interface
+
+import (
+	"github.com/Shopify/go-lua"
+	"ocilot"
+)
+
+func LuaImageAnnotate(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	image, ok := l.ToUserData(1).(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as first parameter")
+		l.Error()
+		return 0
+	}
+	lua.CheckType(l, 2, lua.TypeTable)
+	annotations := make(map[string]string)
+	err := pullOptions(l, 2, &annotations)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	err = image.Annotate(annotations)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	return 0
+}
+
+func LuaImageAnnotateLayer(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	image, ok := l.ToUserData(1).(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as first parameter")
+		l.Error()
+		return 0
+	}
+	index, err := layerIndex(l, image, 2, 0)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	lua.CheckType(l, 3, lua.TypeTable)
+	annotations := make(map[string]string)
+	err = pullOptions(l, 3, &annotations)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	err = image.AnnotateLayer(index, annotations)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	return 0
+}
+
+func LuaImageAnnotateStandard(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	image, ok := l.ToUserData(1).(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as first parameter")
+		l.Error()
+		return 0
+	}
+	options := &ocilot.StandardAnnotations{}
+	err := pullOptions(l, 2, options)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	err = image.AnnotateStandard(options)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	return 0
+}
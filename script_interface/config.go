@@ -0,0 +1,137 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package script_interface
+
+import (
+	"github.com/Shopify/go-lua"
+	"ocilot"
+)
+
+// stringList pulls a list of strings at idx, a single string being a list of one element and nil an empty list
+func stringList(l *lua.State, idx int) ([]string, error) {
+	if l.IsString(idx) {
+		return []string{lua.CheckString(l, idx)}, nil
+	}
+	var res []string
+	err := pullOptions(l, idx, &res)
+	return res, err
+}
+
+// stringSetter returns the binding of an image method taking a single string
+func stringSetter(set func(image *ocilot.Image, value string) error) lua.Function {
+	return func(l *lua.State) int {
+		lua.CheckAny(l, 1)
+		image, ok := l.ToUserData(1).(*ocilot.Image)
+		if !ok {
+			l.PushString("Expected image as first parameter")
+			l.Error()
+			return 0
+		}
+		err := set(image, lua.CheckString(l, 2))
+		if err != nil {
+			l.PushString(err.Error())
+			l.Error()
+			return 0
+		}
+		return 0
+	}
+}
+
+// listSetter returns the binding of an image method taking a list of strings
+func listSetter(set func(image *ocilot.Image, value []string) error) lua.Function {
+	return func(l *lua.State) int {
+		lua.CheckAny(l, 1)
+		image, ok := l.ToUserData(1).(*ocilot.Image)
+		if !ok {
+			l.PushString("Expected image as first parameter")
+			l.Error()
+			return 0
+		}
+		value, err := stringList(l, 2)
+		if err != nil {
+			l.PushString(err.Error())
+			l.Error()
+			return 0
+		}
+		err = set(image, value)
+		if err != nil {
+			l.PushString(err.Error())
+			l.Error()
+			return 0
+		}
+		return 0
+	}
+}
+
+// pairSetter returns the binding of an image method taking a key and a value
+func pairSetter(set func(image *ocilot.Image, key string, value string) error) lua.Function {
+	return func(l *lua.State) int {
+		lua.CheckAny(l, 1)
+		image, ok := l.ToUserData(1).(*ocilot.Image)
+		if !ok {
+			l.PushString("Expected image as first parameter")
+			l.Error()
+			return 0
+		}
+		err := set(image, lua.CheckString(l, 2), lua.CheckString(l, 3))
+		if err != nil {
+			l.PushString(err.Error())
+			l.Error()
+			return 0
+		}
+		return 0
+	}
+}
+
+var (
+	LuaImageSetEnv        = pairSetter((*ocilot.Image).SetEnv)
+	LuaImageUnsetEnv      = stringSetter((*ocilot.Image).UnsetEnv)
+	LuaImageSetEntrypoint = listSetter((*ocilot.Image).SetEntrypoint)
+	LuaImageSetCmd        = listSetter((*ocilot.Image).SetCmd)
+	LuaImageSetWorkdir    = stringSetter((*ocilot.Image).SetWorkdir)
+	LuaImageSetUser       = stringSetter((*ocilot.Image).SetUser)
+	LuaImageExposePort    = stringSetter((*ocilot.Image).ExposePort)
+	LuaImageAddVolume     = stringSetter((*ocilot.Image).AddVolume)
+	LuaImageSetLabel      = pairSetter((*ocilot.Image).SetLabel)
+	LuaImageSetStopSignal = stringSetter((*ocilot.Image).SetStopSignal)
+)
+
+func LuaImageSetHealthcheck(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	image, ok := l.ToUserData(1).(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as first parameter")
+		l.Error()
+		return 0
+	}
+	lua.CheckType(l, 2, lua.TypeTable)
+	healthcheck := &ocilot.Healthcheck{}
+	err := pullOptions(l, 2, healthcheck)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	err = image.SetHealthcheck(healthcheck)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	return 0
+}
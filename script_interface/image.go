@@ -18,7 +18,9 @@
 package script_interface
 
 import (
+	"archive/tar"
 	"encoding/json"
+	"fmt"
 	"github.com/Shopify/go-lua"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/pujo-j/luabox"
@@ -37,6 +39,24 @@ func LuaPullImage(l *lua.State) int {
 	return 1
 }
 
+func LuaImageScratch(l *lua.State) int {
+	platform := v1.Platform{}
+	err := pullOptions(l, 1, &platform)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	newImage, err := ocilot.NewScratchImage(platform)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	l.PushUserData(newImage)
+	return 1
+}
+
 func LuaImageClone(l *lua.State) int {
 	lua.CheckAny(l, 1)
 	i := l.ToUserData(1)
@@ -128,14 +148,18 @@ func LuaImageSetConfig(l *lua.State) int {
 		l.Error()
 		return 0
 	}
-	config := &v1.Config{}
-	err = json.Unmarshal(data, config)
+	config, err := ocilot.ParseConfig(data)
 	if err != nil {
 		l.PushString(err.Error())
 		l.Error()
 		return 0
 	}
 	err = image.WithConfig(config)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
 	return 0
 }
 
@@ -193,7 +217,491 @@ func LuaImageAppendLayer(l *lua.State) int {
 		l.Error()
 		return 0
 	}
-	err := image.AddLayer(layer)
+	history := v1.History{}
+	err := pullOptions(l, 3, &history)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	if history.CreatedBy == "" {
+		history.CreatedBy = scriptLocation(l)
+	}
+	err = image.AppendLayer(layer, history)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	return 0
+}
+
+func LuaImageHistory(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	image, ok := l.ToUserData(1).(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as first parameter")
+		l.Error()
+		return 0
+	}
+	history, err := image.History()
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	bytes, err := json.Marshal(history)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	res := make([]interface{}, 0)
+	err = json.Unmarshal(bytes, &res)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	luabox.DeepPush(l, res)
+	return 1
+}
+
+func LuaImageRemove(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	i := l.ToUserData(1)
+	image, ok := i.(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as first parameter")
+		l.Error()
+		return 0
+	}
+	paths := make([]string, 0)
+	if l.IsString(2) {
+		paths = append(paths, lua.CheckString(l, 2))
+	} else {
+		err := pullOptions(l, 2, &paths)
+		if err != nil {
+			l.PushString(err.Error())
+			l.Error()
+			return 0
+		}
+	}
+	err := image.Remove(paths)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	return 0
+}
+
+// headerTable converts a tar header into a table using the field names of layer entries
+func headerTable(th *tar.Header) map[string]interface{} {
+	return map[string]interface{}{
+		"name":    "/" + th.Name,
+		"size":    th.Size,
+		"mode":    fmt.Sprintf("%04o", th.Mode),
+		"uid":     th.Uid,
+		"gid":     th.Gid,
+		"uname":   th.Uname,
+		"gname":   th.Gname,
+		"modTime": th.ModTime.Unix(),
+	}
+}
+
+func LuaImageReadFile(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	i := l.ToUserData(1)
+	image, ok := i.(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as first parameter")
+		l.Error()
+		return 0
+	}
+	p := lua.CheckString(l, 2)
+	th, content, err := image.ReadFile(p)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	l.PushString(string(content))
+	luabox.DeepPush(l, headerTable(th))
+	return 2
+}
+
+func LuaImagePatchFile(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	i := l.ToUserData(1)
+	image, ok := i.(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as first parameter")
+		l.Error()
+		return 0
+	}
+	p := lua.CheckString(l, 2)
+	lua.CheckType(l, 3, lua.TypeFunction)
+	err := image.PatchFile(p, func(content []byte) ([]byte, error) {
+		l.PushValue(3)
+		l.PushString(string(content))
+		l.Call(1, 1)
+		patched, ok := l.ToString(-1)
+		l.Pop(1)
+		if !ok {
+			return nil, fmt.Errorf("patch function must return the new content")
+		}
+		return []byte(patched), nil
+	})
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	return 0
+}
+
+func LuaImageAddUser(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	i := l.ToUserData(1)
+	image, ok := i.(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as first parameter")
+		l.Error()
+		return 0
+	}
+	lua.CheckType(l, 2, lua.TypeTable)
+	user := &ocilot.User{}
+	err := pullOptions(l, 2, user)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	err = image.AddUser(user)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	return 0
+}
+
+func LuaImageAddGroup(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	i := l.ToUserData(1)
+	image, ok := i.(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as first parameter")
+		l.Error()
+		return 0
+	}
+	lua.CheckType(l, 2, lua.TypeTable)
+	group := &ocilot.Group{}
+	err := pullOptions(l, 2, group)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	err = image.AddGroup(group)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	return 0
+}
+
+func LuaImageCopyFrom(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	i := l.ToUserData(1)
+	image, ok := i.(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as first parameter")
+		l.Error()
+		return 0
+	}
+	lua.CheckAny(l, 2)
+	o := l.ToUserData(2)
+	other, ok := o.(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as second parameter")
+		l.Error()
+		return 0
+	}
+	src := lua.CheckString(l, 3)
+	dest := lua.CheckString(l, 4)
+	options := &ocilot.CopyOptions{}
+	err := pullOptions(l, 5, options)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	err = image.CopyFrom(other, src, dest, options)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	return 0
+}
+
+func LuaImageUnpack(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	i := l.ToUserData(1)
+	image, ok := i.(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as first parameter")
+		l.Error()
+		return 0
+	}
+	dir := lua.CheckString(l, 2)
+	err := image.Unpack(dir)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	return 0
+}
+
+func LuaImagePlatform(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	i := l.ToUserData(1)
+	image, ok := i.(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as first parameter")
+		l.Error()
+		return 0
+	}
+	platform, err := image.Platform()
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	res := map[string]interface{}{
+		"os":           platform.OS,
+		"architecture": platform.Architecture,
+	}
+	if platform.Variant != "" {
+		res["variant"] = platform.Variant
+	}
+	if platform.OSVersion != "" {
+		res["os.version"] = platform.OSVersion
+	}
+	luabox.DeepPush(l, res)
+	return 1
+}
+
+func LuaImagePullIndex(l *lua.State) int {
+	name := lua.CheckString(l, 1)
+	images, err := ocilot.LoadIndex(name)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	l.CreateTable(len(images), 0)
+	for n, image := range images {
+		l.PushUserData(image)
+		l.RawSetInt(-2, n+1)
+	}
+	return 1
+}
+
+func LuaImagePushIndex(l *lua.State) int {
+	name := lua.CheckString(l, 1)
+	lua.CheckType(l, 2, lua.TypeTable)
+	images := make([]*ocilot.Image, 0)
+	for n := 1; ; n++ {
+		l.RawGetInt(2, n)
+		if l.IsNil(-1) {
+			l.Pop(1)
+			break
+		}
+		image, ok := l.ToUserData(-1).(*ocilot.Image)
+		l.Pop(1)
+		if !ok {
+			l.PushString("Expected a list of images as second parameter")
+			l.Error()
+			return 0
+		}
+		images = append(images, image)
+	}
+	options := &ocilot.IndexOptions{}
+	err := pullOptions(l, 3, options)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	err = ocilot.PushIndex(name, images, options)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	return 0
+}
+
+func LuaImageRebase(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	image, ok := l.ToUserData(1).(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as first parameter")
+		l.Error()
+		return 0
+	}
+	lua.CheckAny(l, 2)
+	oldBase, ok := l.ToUserData(2).(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as second parameter")
+		l.Error()
+		return 0
+	}
+	lua.CheckAny(l, 3)
+	newBase, ok := l.ToUserData(3).(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as third parameter")
+		l.Error()
+		return 0
+	}
+	options := &ocilot.RebaseOptions{}
+	err := pullOptions(l, 4, options)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	err = image.Rebase(oldBase, newBase, options)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	return 0
+}
+
+func LuaImageSquash(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	image, ok := l.ToUserData(1).(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as first parameter")
+		l.Error()
+		return 0
+	}
+	options := &ocilot.SquashOptions{}
+	err := pullOptions(l, 2, options)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	err = image.Squash(options)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	return 0
+}
+
+// layerIndex returns the position of the layer designated by a 1 based index or a digest at idx,
+// the index being allowed to go past the last layer by extra
+func layerIndex(l *lua.State, image *ocilot.Image, idx int, extra int) (int, error) {
+	if l.TypeOf(idx) != lua.TypeNumber {
+		return image.LayerIndex(lua.CheckString(l, idx))
+	}
+	index := lua.CheckInteger(l, idx)
+	layers, err := image.Layers()
+	if err != nil {
+		return 0, err
+	}
+	if index < 1 || index > len(layers)+extra {
+		return 0, fmt.Errorf("no layer %d in %s, it has %d layers", index, image, len(layers))
+	}
+	return index - 1, nil
+}
+
+func LuaImageRemoveLayer(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	image, ok := l.ToUserData(1).(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as first parameter")
+		l.Error()
+		return 0
+	}
+	index, err := layerIndex(l, image, 2, 0)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	err = image.RemoveLayer(index)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	return 0
+}
+
+func LuaImageReplaceLayer(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	image, ok := l.ToUserData(1).(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as first parameter")
+		l.Error()
+		return 0
+	}
+	index, err := layerIndex(l, image, 2, 0)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	lua.CheckAny(l, 3)
+	layer, ok := l.ToUserData(3).(v1.Layer)
+	if !ok {
+		l.PushString("Expected layer as third parameter")
+		l.Error()
+		return 0
+	}
+	err = image.ReplaceLayer(index, layer)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	return 0
+}
+
+func LuaImageInsertLayer(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	image, ok := l.ToUserData(1).(*ocilot.Image)
+	if !ok {
+		l.PushString("Expected image as first parameter")
+		l.Error()
+		return 0
+	}
+	lua.CheckType(l, 2, lua.TypeNumber)
+	index, err := layerIndex(l, image, 2, 1)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	lua.CheckAny(l, 3)
+	layer, ok := l.ToUserData(3).(v1.Layer)
+	if !ok {
+		l.PushString("Expected layer as third parameter")
+		l.Error()
+		return 0
+	}
+	err = image.InsertLayer(index, layer)
 	if err != nil {
 		l.PushString(err.Error())
 		l.Error()
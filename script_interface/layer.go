@@ -18,9 +18,11 @@
 package script_interface
 
 import (
+	"fmt"
 	"github.com/Shopify/go-lua"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/pujo-j/luabox"
+	"ocilot"
 )
 
 func LuaLayerString(l *lua.State) int {
@@ -84,3 +86,144 @@ func LuaLayerInfo(l *lua.State) int {
 	luabox.DeepPush(l, res)
 	return 1
 }
+
+// stringField reads the optional string field name of the table at idx
+func stringField(l *lua.State, idx int, name string) (string, error) {
+	l.Field(idx, name)
+	defer l.Pop(1)
+	if l.IsNil(-1) {
+		return "", nil
+	}
+	res, ok := l.ToString(-1)
+	if !ok {
+		return "", fmt.Errorf("field %s should be a string, got %s", name, lua.TypeNameOf(l, -1))
+	}
+	return res, nil
+}
+
+// intField reads the optional integer field name of the table at idx
+func intField(l *lua.State, idx int, name string) (int, error) {
+	l.Field(idx, name)
+	defer l.Pop(1)
+	if l.IsNil(-1) {
+		return 0, nil
+	}
+	res, ok := l.ToInteger(-1)
+	if !ok {
+		return 0, fmt.Errorf("field %s should be an integer, got %s", name, lua.TypeNameOf(l, -1))
+	}
+	return res, nil
+}
+
+// pullLayerEntry reads an entry description, a plain string being the content of a file.
+// Content is read directly from lua to keep binary data intact.
+func pullLayerEntry(l *lua.State, idx int) (*ocilot.LayerEntry, error) {
+	if l.IsString(idx) {
+		content, _ := l.ToString(idx)
+		return &ocilot.LayerEntry{Content: []byte(content)}, nil
+	}
+	if !l.IsTable(idx) {
+		return nil, fmt.Errorf("layer entry should be a string or a table, got %s", lua.TypeNameOf(l, idx))
+	}
+	idx = l.AbsIndex(idx)
+	res := &ocilot.LayerEntry{}
+	var content string
+	var err error
+	for name, target := range map[string]*string{
+		"type": &res.Type, "content": &content, "target": &res.Target,
+		"mode": &res.Mode, "uname": &res.Uname, "gname": &res.Gname,
+	} {
+		*target, err = stringField(l, idx, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	res.Content = []byte(content)
+	for name, target := range map[string]*int{"uid": &res.Uid, "gid": &res.Gid} {
+		*target, err = intField(l, idx, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func LuaLayerBuild(l *lua.State) int {
+	lua.CheckType(l, 1, lua.TypeTable)
+	options := &ocilot.LayerOptions{}
+	err := pullOptions(l, 2, options)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	builder := ocilot.NewLayerBuilder()
+	l.PushNil()
+	for l.Next(1) {
+		if l.TypeOf(-2) != lua.TypeString {
+			l.PushString("layer entries should be indexed by path")
+			l.Error()
+			return 0
+		}
+		name, _ := l.ToString(-2)
+		entry, err := pullLayerEntry(l, -1)
+		if err != nil {
+			l.PushString(name + ": " + err.Error())
+			l.Error()
+			return 0
+		}
+		err = builder.Add(name, entry)
+		if err != nil {
+			l.PushString(err.Error())
+			l.Error()
+			return 0
+		}
+		l.Pop(1)
+	}
+	layer, err := builder.Build("", options)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	l.PushUserData(layer)
+	return 1
+}
+
+func LuaLayerFromTar(l *lua.State) int {
+	tarPath := lua.CheckString(l, 1)
+	options := &ocilot.ImportOptions{}
+	err := pullOptions(l, 2, options)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	layer, err := ocilot.LayerFromTar(tarPath, options)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	l.PushUserData(layer)
+	return 1
+}
+
+func LuaLayerFromZip(l *lua.State) int {
+	zipPath := lua.CheckString(l, 1)
+	options := &ocilot.ImportOptions{}
+	err := pullOptions(l, 2, options)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	layer, err := ocilot.LayerFromZip(zipPath, options)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	l.PushUserData(layer)
+	return 1
+}
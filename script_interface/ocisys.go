@@ -24,32 +24,68 @@ import (
 
 var ocisys = []lua.RegistryFunction{
 
-	{"snap", LuaSnapShot},
-	{"snapDiff", LuaDiff},
-	{"snapAsLayer", LuaSnapAsLayer},
-	{"snapFiles", LuaSnapFiles},
-	{"snapString", LuaSnapString},
-
-	{"layerInfo", LuaLayerInfo},
-	{"layerString", LuaLayerString},
-
-	{"imagePull", LuaPullImage},
-	{"imagePush", LuaImagePush},
-	{"imageClone", LuaImageClone},
-	{"imageGetConfig", LuaImageGetConfig},
-	{"imageSetConfig", LuaImageSetConfig},
-	{"imageGetLayers", LuaImageGetLayers},
-	{"imageString", LuaImageString},
-	{"imageAppendLayer", LuaImageAppendLayer},
-
-	{"cacheGetImage", LuaGetImageFromCache},
-
-	{"shellExec", LuaShellExec},
-	{"shellString", LuaShellString},
-
-	{"hash", LuaHash},
-	{"read", LuaRead},
-	{"write", LuaWrite},
+	{Name: "snap", Function: LuaSnapShot},
+	{Name: "snapDiff", Function: LuaDiff},
+	{Name: "snapAsLayer", Function: LuaSnapAsLayer},
+	{Name: "snapFiles", Function: LuaSnapFiles},
+	{Name: "snapString", Function: LuaSnapString},
+	{Name: "snapHash", Function: LuaSnapHash},
+	{Name: "snapStats", Function: LuaSnapStats},
+
+	{Name: "layerInfo", Function: LuaLayerInfo},
+	{Name: "layerString", Function: LuaLayerString},
+	{Name: "layerBuild", Function: LuaLayerBuild},
+	{Name: "layerFromTar", Function: LuaLayerFromTar},
+	{Name: "layerFromZip", Function: LuaLayerFromZip},
+
+	{Name: "imagePull", Function: LuaPullImage},
+	{Name: "imageScratch", Function: LuaImageScratch},
+	{Name: "imagePush", Function: LuaImagePush},
+	{Name: "imageClone", Function: LuaImageClone},
+	{Name: "imageGetConfig", Function: LuaImageGetConfig},
+	{Name: "imageSetConfig", Function: LuaImageSetConfig},
+	{Name: "imageSetEnv", Function: LuaImageSetEnv},
+	{Name: "imageUnsetEnv", Function: LuaImageUnsetEnv},
+	{Name: "imageSetEntrypoint", Function: LuaImageSetEntrypoint},
+	{Name: "imageSetCmd", Function: LuaImageSetCmd},
+	{Name: "imageSetWorkdir", Function: LuaImageSetWorkdir},
+	{Name: "imageSetUser", Function: LuaImageSetUser},
+	{Name: "imageExposePort", Function: LuaImageExposePort},
+	{Name: "imageAddVolume", Function: LuaImageAddVolume},
+	{Name: "imageSetLabel", Function: LuaImageSetLabel},
+	{Name: "imageSetStopSignal", Function: LuaImageSetStopSignal},
+	{Name: "imageSetHealthcheck", Function: LuaImageSetHealthcheck},
+	{Name: "imageGetLayers", Function: LuaImageGetLayers},
+	{Name: "imageString", Function: LuaImageString},
+	{Name: "imageAppendLayer", Function: LuaImageAppendLayer},
+	{Name: "imageHistory", Function: LuaImageHistory},
+	{Name: "imageRemove", Function: LuaImageRemove},
+	{Name: "imageReadFile", Function: LuaImageReadFile},
+	{Name: "imagePatchFile", Function: LuaImagePatchFile},
+	{Name: "imageAddUser", Function: LuaImageAddUser},
+	{Name: "imageAddGroup", Function: LuaImageAddGroup},
+	{Name: "imageCopyFrom", Function: LuaImageCopyFrom},
+	{Name: "imageUnpack", Function: LuaImageUnpack},
+	{Name: "imagePlatform", Function: LuaImagePlatform},
+	{Name: "imagePullIndex", Function: LuaImagePullIndex},
+	{Name: "imagePushIndex", Function: LuaImagePushIndex},
+	{Name: "imageRebase", Function: LuaImageRebase},
+	{Name: "imageSquash", Function: LuaImageSquash},
+	{Name: "imageRemoveLayer", Function: LuaImageRemoveLayer},
+	{Name: "imageReplaceLayer", Function: LuaImageReplaceLayer},
+	{Name: "imageInsertLayer", Function: LuaImageInsertLayer},
+	{Name: "imageAnnotate", Function: LuaImageAnnotate},
+	{Name: "imageAnnotateLayer", Function: LuaImageAnnotateLayer},
+	{Name: "imageAnnotateStandard", Function: LuaImageAnnotateStandard},
+
+	{Name: "cacheGetImage", Function: LuaGetImageFromCache},
+
+	{Name: "shellExec", Function: LuaShellExec},
+	{Name: "shellString", Function: LuaShellString},
+
+	{Name: "hash", Function: LuaHash},
+	{Name: "read", Function: LuaRead},
+	{Name: "write", Function: LuaWrite},
 }
 
 func OciSysOpen(l *lua.State) int {
@@ -25,11 +25,13 @@ import (
 	"github.com/pujo-j/luabox/localenv"
 	"go.uber.org/zap"
 	"io/ioutil"
+	"ocilot"
 	"os"
 	"path"
 )
 
 func NewEnv(args []string, log *zap.SugaredLogger, libFolder string) (*luabox.Environment, error) {
+	ocilot.SetLogger(log)
 	fs := luabox.VFS{}
 	fs.BaseFs = &localenv.Fs{BaseDir: path.Clean(libFolder)}
 	fs.Prefixes = map[string]luabox.Filesystem{}
@@ -58,7 +60,7 @@ func NewEnv(args []string, log *zap.SugaredLogger, libFolder string) (*luabox.En
 
 	}
 	golibs := []lua.RegistryFunction{
-		{"ocisys", OciSysOpen},
+		{Name: "ocisys", Function: OciSysOpen},
 	}
 	res := luabox.Environment{
 		Fs:      &fs,
@@ -19,6 +19,7 @@ package script_interface
 
 import (
 	"github.com/Shopify/go-lua"
+	"github.com/dustin/go-humanize"
 	"github.com/pujo-j/luabox"
 	"ocilot"
 )
@@ -38,7 +39,14 @@ func LuaSnapString(l *lua.State) int {
 
 func LuaSnapShot(l *lua.State) int {
 	filePath := lua.CheckString(l, 1)
-	snapshot, err := ocilot.NewSnapshot(filePath)
+	options := &ocilot.SnapshotOptions{}
+	err := pullOptions(l, 2, options)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	snapshot, err := ocilot.NewSnapshot(filePath, options)
 	if err != nil {
 		l.PushString(err.Error())
 		l.Error()
@@ -77,7 +85,7 @@ func LuaDiff(l *lua.State) int {
 
 func LuaSnapAsLayer(l *lua.State) int {
 	lua.CheckAny(l, 1)
-	workFile := lua.CheckString(l, 2)
+	workFile := lua.OptString(l, 2, "")
 	s1i := l.ToUserData(1)
 	s1, ok := s1i.(*ocilot.Snapshot)
 	if !ok {
@@ -85,7 +93,14 @@ func LuaSnapAsLayer(l *lua.State) int {
 		l.Error()
 		return 0
 	}
-	layer, err := s1.AsLayer(workFile)
+	options := &ocilot.LayerOptions{}
+	err := pullOptions(l, 3, options)
+	if err != nil {
+		l.PushString(err.Error())
+		l.Error()
+		return 0
+	}
+	layer, err := s1.AsLayer(workFile, options)
 	if err != nil {
 		l.PushString(err.Error())
 		l.Error()
@@ -111,3 +126,35 @@ func LuaSnapFiles(l *lua.State) int {
 	luabox.DeepPush(l, res)
 	return 1
 }
+
+func LuaSnapHash(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	s1i := l.ToUserData(1)
+	s1, ok := s1i.(*ocilot.Snapshot)
+	if !ok {
+		l.PushString("Expected snapshot as parameter")
+		l.Error()
+		return 0
+	}
+	l.PushString(s1.TreeHash())
+	return 1
+}
+
+func LuaSnapStats(l *lua.State) int {
+	lua.CheckAny(l, 1)
+	s1i := l.ToUserData(1)
+	s1, ok := s1i.(*ocilot.Snapshot)
+	if !ok {
+		l.PushString("Expected snapshot as parameter")
+		l.Error()
+		return 0
+	}
+	size := s1.TotalSize()
+	res := map[string]interface{}{
+		"files": s1.Count(),
+		"bytes": size,
+		"size":  humanize.Bytes(uint64(size)),
+	}
+	luabox.DeepPush(l, res)
+	return 1
+}
@@ -1,13 +1,59 @@
 package script_interface
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"github.com/Shopify/go-lua"
+	"github.com/pujo-j/luabox"
 	"io/ioutil"
 	"os"
+	"strings"
 )
 
+// preludeChunk is the chunk name of the embedded ocilot_init.lua
+const preludeChunk = "ocilot"
+
+// scriptLocation returns the script name and line of the innermost caller outside of go functions and the prelude
+func scriptLocation(l *lua.State) string {
+	for level := 0; ; level++ {
+		frame, ok := lua.Stack(l, level)
+		if !ok {
+			return ""
+		}
+		d, ok := lua.Info(l, "Sl", frame)
+		if !ok || d.What == "Go" || d.Source == preludeChunk || d.CurrentLine <= 0 {
+			continue
+		}
+		return fmt.Sprintf("%s:%d", strings.TrimLeft(d.Source, "@="), d.CurrentLine)
+	}
+}
+
+// pullOptions decodes an optional lua table at idx into v, going through json like the image config
+func pullOptions(l *lua.State, idx int, v interface{}) error {
+	if l.IsNoneOrNil(idx) {
+		return nil
+	}
+	p, err := luabox.PullTable(l, idx)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(v)
+	if err != nil {
+		message := strings.TrimPrefix(err.Error(), "json: ")
+		return fmt.Errorf("invalid options: %s", strings.Replace(message, "unknown field", "unknown key", 1))
+	}
+	return nil
+}
+
 func LuaHash(l *lua.State) int {
 	data := lua.CheckString(l, 1)
 	h := sha256.New()
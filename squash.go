@@ -0,0 +1,276 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+)
+
+// SquashOptions controls how layers are merged
+type SquashOptions struct {
+	// From is the number of bottom layers kept as they are, all the layers above being merged
+	From int `json:"from"`
+	LayerOptions
+}
+
+func (o *SquashOptions) layerOptions() *LayerOptions {
+	if o == nil {
+		return nil
+	}
+	return &o.LayerOptions
+}
+
+// squashEntry is the version of a file kept in a squashed layer
+type squashEntry struct {
+	layer int
+	// source is the file a hardlink pointed to when it was created, with the layer of its version at that time
+	source      string
+	sourceLayer int
+}
+
+// squashState merges layer headers, bottom-up, recording the whiteouts that still apply to the kept layers below
+type squashState struct {
+	files   map[string]*squashEntry
+	deleted map[string]bool
+	opaque  map[string]bool
+}
+
+// hide drops the merged files below dir, dir itself too unless strict
+func (s *squashState) hide(dir string, strict bool, before int) {
+	for name, entry := range s.files {
+		if (!strict || name != dir) && isBelow(name, dir) && entry.layer < before {
+			delete(s.files, name)
+		}
+	}
+	for _, marks := range []map[string]bool{s.deleted, s.opaque} {
+		for name := range marks {
+			if name != dir && isBelow(name, dir) {
+				delete(marks, name)
+			}
+		}
+	}
+}
+
+func (s *squashState) apply(index int, entries []*tar.Header) {
+	for _, th := range entries {
+		base := path.Base(th.Name)
+		dir := path.Dir(th.Name)
+		if dir == "." {
+			dir = ""
+		}
+		switch {
+		case base == OpaqueWhiteout:
+			s.hide(dir, true, index)
+			s.opaque[dir] = true
+		case strings.HasPrefix(base, WhiteoutPrefix):
+			name := path.Join(dir, strings.TrimPrefix(base, WhiteoutPrefix))
+			s.hide(name, false, index)
+			delete(s.opaque, name)
+			s.deleted[name] = true
+		}
+	}
+	for _, th := range entries {
+		if IsWhiteout(th.Name) {
+			continue
+		}
+		// A deleted directory coming back must not show its former content
+		for dir := th.Name; dir != "."; dir = path.Dir(dir) {
+			if s.deleted[dir] {
+				delete(s.deleted, dir)
+				if dir != th.Name || th.Typeflag == tar.TypeDir {
+					s.opaque[dir] = true
+				}
+			}
+		}
+		if previous, ok := s.files[th.Name]; ok && th.Typeflag != tar.TypeDir {
+			if previous.layer < index {
+				s.hide(th.Name, true, index)
+			}
+		}
+		entry := &squashEntry{layer: index}
+		if th.Typeflag == tar.TypeLink {
+			entry.source = th.Linkname
+			entry.sourceLayer = -1
+			if source, ok := s.files[th.Linkname]; ok {
+				entry.sourceLayer = source.layer
+			}
+		}
+		s.files[th.Name] = entry
+	}
+}
+
+// squashLayers merges layers into a single one, whiteouts being kept when lower layers remain below it
+func squashLayers(layers []v1.Layer, keepWhiteouts bool, options *LayerOptions) (v1.Layer, error) {
+	state := &squashState{
+		files:   make(map[string]*squashEntry),
+		deleted: make(map[string]bool),
+		opaque:  make(map[string]bool),
+	}
+	for index, layer := range layers {
+		entries := make([]*tar.Header, 0)
+		err := readLayer(layer, func(th *tar.Header, r io.Reader) error {
+			entries = append(entries, th)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("reading layer %d: %v", index, err)
+		}
+		state.apply(index, entries)
+	}
+	// Hardlinks whose target changed since their creation become regular files
+	sources := make(map[string]map[int]bool)
+	for _, entry := range state.files {
+		if entry.source == "" || entry.sourceLayer < 0 {
+			continue
+		}
+		if target, ok := state.files[entry.source]; ok && target.layer == entry.sourceLayer {
+			continue
+		}
+		if sources[entry.source] == nil {
+			sources[entry.source] = make(map[int]bool)
+		}
+		sources[entry.source][entry.sourceLayer] = true
+	}
+	epoch, err := options.epoch()
+	if err != nil {
+		return nil, err
+	}
+	markers := make([]string, 0)
+	if keepWhiteouts {
+		for name := range state.deleted {
+			markers = append(markers, path.Join(path.Dir(name), WhiteoutPrefix+path.Base(name)))
+		}
+		for dir := range state.opaque {
+			markers = append(markers, path.Join(dir, OpaqueWhiteout))
+		}
+		sort.Strings(markers)
+	}
+	contents := make(map[string][]byte)
+	return writeLayer("", options, func(writer *tar.Writer) error {
+		for _, marker := range markers {
+			err := writer.WriteHeader(normalizeHeader(&tar.Header{
+				Name:     marker,
+				Typeflag: tar.TypeReg,
+				Mode:     0644,
+				ModTime:  entryModTime,
+			}, epoch))
+			if err != nil {
+				return err
+			}
+		}
+		for index, layer := range layers {
+			err := readLayer(layer, func(th *tar.Header, r io.Reader) error {
+				var content io.Reader = r
+				if sources[th.Name][index] {
+					data, err := ioutil.ReadAll(r)
+					if err != nil {
+						return err
+					}
+					contents[fmt.Sprintf("%d:%s", index, th.Name)] = data
+					content = bytes.NewReader(data)
+				}
+				entry, ok := state.files[th.Name]
+				if !ok || entry.layer != index || IsWhiteout(th.Name) {
+					return nil
+				}
+				header := *th
+				if header.Typeflag == tar.TypeRegA {
+					header.Typeflag = tar.TypeReg
+				}
+				if header.Typeflag == tar.TypeLink && sources[entry.source][entry.sourceLayer] {
+					data := contents[fmt.Sprintf("%d:%s", entry.sourceLayer, entry.source)]
+					header.Typeflag = tar.TypeReg
+					header.Linkname = ""
+					header.Size = int64(len(data))
+					content = bytes.NewReader(data)
+				}
+				err := writer.WriteHeader(normalizeHeader(&header, epoch))
+				if err != nil {
+					return err
+				}
+				if header.Typeflag == tar.TypeReg {
+					_, err = io.Copy(writer, content)
+				}
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("reading layer %d: %v", index, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Squash merges the layers above the first options.From ones into a single layer, dropping overwritten and deleted files.
+// The history entries of the merged layers are replaced by a single one.
+func (i *Image) Squash(options *SquashOptions) error {
+	from := 0
+	if options != nil {
+		from = options.From
+	}
+	layers, err := i.img.Layers()
+	if err != nil {
+		return err
+	}
+	if from < 0 || from > len(layers) {
+		return fmt.Errorf("cannot squash %s from layer %d, it has %d layers", i, from, len(layers))
+	}
+	if len(layers)-from < 2 {
+		return nil
+	}
+	squashed, err := squashLayers(layers[from:], from > 0, options.layerOptions())
+	if err != nil {
+		return fmt.Errorf("squashing %s: %v", i, err)
+	}
+	configFile, err := i.img.ConfigFile()
+	if err != nil {
+		return err
+	}
+	groups, trailing := layerHistory(configFile, len(layers))
+	merged := make([]v1.History, 0)
+	for _, group := range groups[from:] {
+		for _, h := range group {
+			if h.EmptyLayer {
+				merged = append(merged, h)
+			}
+		}
+	}
+	created, err := creationTime()
+	if err != nil {
+		return err
+	}
+	merged = append(merged, v1.History{
+		Created:   v1.Time{Time: created},
+		CreatedBy: "ocilot squash",
+		Comment:   fmt.Sprintf("%d layers squashed", len(layers)-from),
+	})
+	img, err := rebuildImage(i.img, append(append([]v1.Layer{}, layers[:from]...), squashed), append(groups[:from:from], merged), trailing)
+	if err != nil {
+		return err
+	}
+	i.img = img
+	return nil
+}
@@ -0,0 +1,45 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"testing"
+)
+
+func TestSquashHistoryEpoch(t *testing.T) {
+	setEpoch(t, "1600000000")
+	image := layersImage(t,
+		buildLayer(t, "/a", "a", nil),
+		buildLayer(t, "/b", "b", nil),
+		buildLayer(t, "/a", "a2", nil),
+	)
+	err := image.Squash(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := image.History()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Created.Unix() != 1600000000 {
+		t.Fatalf("expected a single squash entry created at the epoch, got %+v", history)
+	}
+	if content := readContent(t, image, "/a"); content != "a2" {
+		t.Errorf("expected a2, got %q", content)
+	}
+}
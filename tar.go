@@ -19,7 +19,6 @@ package ocilot
 
 import (
 	"archive/tar"
-	"compress/gzip"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -29,7 +28,11 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -37,12 +40,34 @@ type Snapshot struct {
 	Name     string
 	Creation time.Time
 	Files    map[string]*tar.Header
+	// Digests holds the hex sha256 of the regular files content, when the snapshot was taken with Digest enabled
+	Digests map[string]string
+	// dirIDs holds the identity of the directories, telling a directory deleted and created again from a kept one
+	dirIDs map[string]fileID
+	// hostPrefix is the host path the entry names start with when the snapshot has no destination
+	hostPrefix string
 }
 
 func (s *Snapshot) String() string {
 	return s.Name + "@" + s.Creation.Format("15:04:05.000")
 }
 
+// Count returns the number of entries in the snapshot
+func (s *Snapshot) Count() int {
+	return len(s.Files)
+}
+
+// TotalSize returns the cumulated size of the regular files of the snapshot
+func (s *Snapshot) TotalSize() int64 {
+	var res int64
+	for _, th := range s.Files {
+		if th.Typeflag == tar.TypeReg {
+			res += th.Size
+		}
+	}
+	return res
+}
+
 type countingWriter struct {
 	Size int64
 }
@@ -53,136 +78,461 @@ func (c *countingWriter) Write(p []byte) (n int, err error) {
 	return l, nil
 }
 
-func (s *Snapshot) AsLayer(workFile string) (v1.Layer, error) {
-	file, err := os.Create(workFile)
-	defer func() {
-		_ = file.Close()
-	}()
+// LayerOptions controls how a snapshot is written as a layer
+type LayerOptions struct {
+	// Epoch clamps every timestamp to this unix time, SOURCE_DATE_EPOCH is used when unset
+	Epoch *int64 `json:"epoch"`
+	// Compression is one of CompressionGzip, CompressionZstd or CompressionNone, DefaultCompression when empty
+	Compression string `json:"compression"`
+	// Level is the compression level, DefaultCompressionLevel or the algorithm default when unset
+	Level *int `json:"level"`
+	// Parallel compresses gzip layers on all cores, zstd layers always are
+	Parallel bool `json:"parallel"`
+}
+
+// sourceDateEpoch returns the time set by SOURCE_DATE_EPOCH, nil when it is not set
+func sourceDateEpoch() (*time.Time, error) {
+	env := os.Getenv("SOURCE_DATE_EPOCH")
+	if env == "" {
+		return nil, nil
+	}
+	epoch, err := strconv.ParseInt(env, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %v", env, err)
+	}
+	res := time.Unix(epoch, 0).UTC()
+	return &res, nil
+}
+
+// creationTime returns the time recorded in new configs, history entries and annotations:
+// SOURCE_DATE_EPOCH when set, the current time otherwise, to the second
+func creationTime() (time.Time, error) {
+	epoch, err := sourceDateEpoch()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if epoch != nil {
+		return *epoch, nil
+	}
+	return time.Now().UTC().Truncate(time.Second), nil
+}
+
+func (o *LayerOptions) epoch() (*time.Time, error) {
+	if o != nil && o.Epoch != nil {
+		res := time.Unix(*o.Epoch, 0).UTC()
+		return &res, nil
+	}
+	return sourceDateEpoch()
+}
+
+// normalizeHeader returns a copy of th stripped of the host specific fields that would make layers irreproducible
+func normalizeHeader(th *tar.Header, epoch *time.Time) *tar.Header {
+	res := *th
+	res.ModTime = res.ModTime.Truncate(time.Second).UTC()
+	if epoch != nil && res.ModTime.After(*epoch) {
+		res.ModTime = *epoch
+	}
+	res.AccessTime = time.Time{}
+	res.ChangeTime = time.Time{}
+	res.Format = tar.FormatUnknown
+	if res.Typeflag != tar.TypeReg {
+		res.Size = 0
+	}
+	return &res
+}
+
+// sortedPaths returns the keys of files in tar entry name order, so parents always come before their children
+func sortedPaths(files map[string]*tar.Header) []string {
+	res := make([]string, 0, len(files))
+	for p := range files {
+		res = append(res, p)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return files[res[i]].Name < files[res[j]].Name
+	})
+	return res
+}
+
+// writeLayer streams the tar produced by write through the compressor into workFile,
+// hashing the uncompressed and compressed streams in the same pass.
+// An empty workFile is replaced by a new file in the WorkDir.
+func writeLayer(workFile string, options *LayerOptions, write func(writer *tar.Writer) error) (*SnapshotLayer, error) {
+	compression, level, err := options.compression()
 	if err != nil {
 		return nil, err
 	}
-	hasher := sha256.New()
-	multiWriter := io.MultiWriter(file, hasher)
-	writer := tar.NewWriter(multiWriter)
-	for path, header := range s.Files {
-		err := writer.WriteHeader(header)
+	extension := ""
+	if compression != CompressionNone {
+		extension = compressedExtension(compression)
+	}
+	if workFile == "" {
+		workFile, err = newWorkFile("layer-*.tar" + extension)
 		if err != nil {
 			return nil, err
 		}
-		if header.Typeflag != tar.TypeDir {
-			f, err := os.Open(path)
-			if err != nil {
-				return nil, err
-			}
-			_, err = io.Copy(writer, f)
-			_ = f.Close()
-			if err != nil {
-				fmt.Printf("Error copying %v , type: %s", header, string(header.Typeflag))
-				return nil, err
-			}
-		}
+	} else {
+		workFile += extension
 	}
-	err = writer.Close()
-	diffId := v1.Hash{
-		Algorithm: "sha256",
-		Hex:       hex.EncodeToString(hasher.Sum(make([]byte, 0, hasher.Size()))),
-	}
-	compressedFile, err := os.Create(workFile + ".gz")
+	file, err := os.Create(workFile)
 	if err != nil {
 		return nil, err
 	}
 	defer func() {
-		_ = compressedFile.Close()
+		_ = file.Close()
 	}()
 	counter := &countingWriter{}
 	compressedHasher := sha256.New()
-	newWriter, err := gzip.NewWriterLevel(io.MultiWriter(compressedFile, counter, compressedHasher), 2)
-	if err != nil {
-		return nil, err
+	output := io.MultiWriter(file, counter, compressedHasher)
+	var compressor io.WriteCloser
+	if compression != CompressionNone {
+		compressor, err = newCompressor(output, compression, level, options != nil && options.Parallel)
+		if err != nil {
+			return nil, err
+		}
+		output = compressor
 	}
-	_, err = file.Seek(0, 0)
+	hasher := sha256.New()
+	writer := tar.NewWriter(io.MultiWriter(output, hasher))
+	err = write(writer)
 	if err != nil {
 		return nil, err
 	}
-	_, err = io.Copy(newWriter, file)
+	err = writer.Close()
 	if err != nil {
 		return nil, err
 	}
-	err = newWriter.Close()
+	if compressor != nil {
+		err = compressor.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = file.Close()
 	if err != nil {
 		return nil, err
 	}
+	diffId := v1.Hash{
+		Algorithm: "sha256",
+		Hex:       hex.EncodeToString(hasher.Sum(make([]byte, 0, hasher.Size()))),
+	}
 	digest := v1.Hash{
 		Algorithm: "sha256",
 		Hex:       hex.EncodeToString(compressedHasher.Sum(make([]byte, 0, compressedHasher.Size()))),
 	}
 	return &SnapshotLayer{
-		tarFile:        workFile,
-		compressedFile: workFile + ".gz",
+		file:           workFile,
+		compression:    compression,
 		diffID:         diffId,
 		digest:         digest,
 		compressedSize: counter.Size,
-		mediaType:      types.DockerLayer,
+		mediaType:      layerMediaType(compression),
 	}, nil
 }
 
-func NewSnapshot(dirPath string) (*Snapshot, error) {
+// AsLayer writes the snapshot entries as a layer, workFile is optional
+func (s *Snapshot) AsLayer(workFile string, options *LayerOptions) (v1.Layer, error) {
+	epoch, err := options.epoch()
+	if err != nil {
+		return nil, err
+	}
+	return writeLayer(workFile, options, func(writer *tar.Writer) error {
+		for _, path := range sortedPaths(s.Files) {
+			header := normalizeHeader(s.Files[path], epoch)
+			if header.Name == "" {
+				continue
+			}
+			err := writer.WriteHeader(header)
+			if err != nil {
+				return err
+			}
+			if header.Typeflag == tar.TypeReg && header.Size > 0 {
+				f, err := os.Open(path)
+				if err != nil {
+					return err
+				}
+				_, err = io.Copy(writer, f)
+				_ = f.Close()
+				if err != nil {
+					return fmt.Errorf("copying %s: %v", path, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// SnapshotOptions controls how the files of a directory are mapped into a layer
+type SnapshotOptions struct {
+	// Dest is the in-image directory the snapshot root is mapped to,
+	// when empty entries keep their absolute host path
+	Dest string `json:"dest"`
+	// Uid, Gid, Uname and Gname set the owner of every entry, root by default
+	Uid   int    `json:"uid"`
+	Gid   int    `json:"gid"`
+	Uname string `json:"uname"`
+	Gname string `json:"gname"`
+	// FileMode and DirMode replace the host permissions of regular files and directories, as octal strings
+	FileMode string `json:"fileMode"`
+	DirMode  string `json:"dirMode"`
+	// Rules are applied in order after the global settings
+	Rules []PermissionRule `json:"rules"`
+	// Digest records the sha256 of every regular file, Diff then compares content instead of modification times
+	Digest bool `json:"digest"`
+	// Include restricts the snapshot to the entries matching these gitignore style patterns, and their parents
+	Include []string `json:"include"`
+	// Exclude removes the entries matching these gitignore style patterns, after the ones from the ignore file
+	Exclude []string `json:"exclude"`
+	// NoIgnoreFile disables the lookup of IgnoreFiles at the snapshot root
+	NoIgnoreFile bool `json:"noIgnoreFile"`
+	// Dereference archives the targets of symlinks instead of the links themselves
+	Dereference bool `json:"dereference"`
+	// SpecialFiles archives named pipes and device nodes, they are skipped with a warning otherwise
+	SpecialFiles bool `json:"specialFiles"`
+}
+
+func NewSnapshot(dirPath string, options *SnapshotOptions) (*Snapshot, error) {
 	humanize.Time(time.Now())
+	err := options.validate()
+	if err != nil {
+		return nil, err
+	}
 	res := &Snapshot{
-		Name:  dirPath,
-		Files: make(map[string]*tar.Header),
+		Name:     dirPath,
+		Creation: time.Now(),
+		Files:    make(map[string]*tar.Header),
+		dirIDs:   make(map[string]fileID),
+	}
+	if options != nil && options.Digest {
+		res.Digests = make(map[string]string)
 	}
-	err := filepath.Walk(dirPath, func(filePath string, info os.FileInfo, err error) error {
-		fullPath, err := filepath.Abs(filePath)
+	filter, err := newSnapshotFilter(dirPath, options)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	walker, err := newSnapshotWalker(dirPath, options, filter, res)
+	if err != nil {
+		return nil, err
+	}
+	if options == nil || options.Dest == "" {
+		res.hostPrefix = walker.absRoot
+	}
+	err = walker.run(info)
+	if err != nil {
+		return nil, err
+	}
+	res.dropExcludedDirs(walker.excludedDirs)
+	res.applyIncludes(filter, walker.rels)
+	walker.linkHardlinks()
+	if res.Digests != nil {
+		err = res.computeDigests()
 		if err != nil {
-			return err
+			return nil, err
 		}
-		fullPath = strings.Replace(fullPath, "\\", "/", -1)
-		symlink, err := filepath.EvalSymlinks(fullPath)
-		if err != nil {
-			return err
-		}
-		symlink = strings.Replace(symlink, "\\", "/", -1)
-		th := tar.Header{}
-		th.Name = fullPath
-		th.ModTime = info.ModTime()
-		th.Size = info.Size()
-		if symlink != th.Name {
-			th.Typeflag = tar.TypeSymlink
-			th.Linkname = symlink
-		} else {
-			if info.IsDir() {
-				th.Typeflag = tar.TypeDir
-			} else {
-				th.Typeflag = tar.TypeReg
+	}
+	return res, nil
+}
+
+type digestResult struct {
+	filePath string
+	digest   string
+	err      error
+}
+
+// computeDigests hashes the regular files of the snapshot on all cores
+func (s *Snapshot) computeDigests() error {
+	paths := make(chan string)
+	results := make(chan digestResult)
+	var workers sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for filePath := range paths {
+				digest, err := fileDigest(filePath)
+				results <- digestResult{filePath: filePath, digest: digest, err: err}
+			}
+		}()
+	}
+	go func() {
+		defer close(paths)
+		for filePath, th := range s.Files {
+			if th.Typeflag == tar.TypeReg {
+				paths <- filePath
 			}
 		}
-		res.Files[filePath] = &th
-		return nil
-	})
-	return res, err
+	}()
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+	var err error
+	for result := range results {
+		if result.err != nil && err == nil {
+			err = result.err
+		}
+		s.Digests[result.filePath] = result.digest
+	}
+	return err
+}
+
+// applyIncludes drops the entries not selected by the include patterns, keeping the directories leading to selected ones
+func (s *Snapshot) applyIncludes(filter *snapshotFilter, rels map[string]string) {
+	if len(filter.include) == 0 {
+		return
+	}
+	keep := make(map[string]bool)
+	for filePath, th := range s.Files {
+		if !filter.included(rels[filePath], th.Typeflag == tar.TypeDir) {
+			continue
+		}
+		for p := filePath; !keep[p]; p = filepath.Dir(p) {
+			keep[p] = true
+			if _, ok := s.Files[p]; !ok || rels[p] == "." {
+				break
+			}
+		}
+	}
+	for filePath := range s.Files {
+		if !keep[filePath] {
+			delete(s.Files, filePath)
+		}
+	}
 }
 
+// dropExcludedDirs removes the excluded directories that were only walked for negations and do not lead to any entry
+func (s *Snapshot) dropExcludedDirs(dirs map[string]bool) {
+	if len(dirs) == 0 {
+		return
+	}
+	keep := make(map[string]bool)
+	for filePath := range s.Files {
+		if dirs[filePath] {
+			continue
+		}
+		for p := filepath.Dir(filePath); dirs[p] && !keep[p]; p = filepath.Dir(p) {
+			keep[p] = true
+		}
+	}
+	for filePath := range dirs {
+		if !keep[filePath] {
+			delete(s.Files, filePath)
+		}
+	}
+}
+
+func fileDigest(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func metadataChanged(old *tar.Header, new *tar.Header) bool {
+	return new.Size != old.Size || new.Typeflag != old.Typeflag || new.Linkname != old.Linkname ||
+		new.Mode != old.Mode || new.Uid != old.Uid || new.Gid != old.Gid
+}
+
+// changed compares the content digests of key when both snapshots have them, the modification times otherwise
+func changed(old *Snapshot, new *Snapshot, key string) bool {
+	oldHeader, newHeader := old.Files[key], new.Files[key]
+	if metadataChanged(oldHeader, newHeader) {
+		return true
+	}
+	if old.Digests != nil && new.Digests != nil {
+		return old.Digests[key] != new.Digests[key]
+	}
+	return !newHeader.ModTime.Equal(oldHeader.ModTime)
+}
+
+// TreeHash returns a sha256 of the snapshot entries metadata, suitable as a cache key.
+// When digests were recorded, file contents are hashed instead of modification times.
+// Entry names are hashed relative to the snapshot root when it has no destination, so the hash does not depend on
+// where the tree is checked out.
+func (s *Snapshot) TreeHash() string {
+	h := sha256.New()
+	for _, key := range sortedPaths(s.Files) {
+		th := s.Files[key]
+		content, ok := s.Digests[key]
+		if !ok && s.Digests == nil {
+			content = strconv.FormatInt(th.ModTime.UnixNano(), 10)
+		}
+		name := strings.TrimPrefix(th.Name, s.hostPrefix)
+		linkname := th.Linkname
+		if th.Typeflag == tar.TypeLink {
+			linkname = strings.TrimPrefix(linkname, s.hostPrefix)
+		}
+		_, _ = fmt.Fprintf(h, "%s\x00%c\x00%o\x00%d\x00%d\x00%s\x00%d\x00%s\n",
+			name, th.Typeflag, th.Mode, th.Uid, th.Gid, linkname, th.Size, content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// addLinkTargets adds to res the files of new its hard links point to, so the links can be resolved within the layer
+func addLinkTargets(res *Snapshot, new *Snapshot) {
+	var names map[string]string
+	for _, th := range res.Files {
+		if th.Typeflag != tar.TypeLink {
+			continue
+		}
+		if names == nil {
+			names = make(map[string]string, len(new.Files))
+			for key, th := range new.Files {
+				names[th.Name] = key
+			}
+		}
+		key, ok := names[th.Linkname]
+		if _, found := res.Files[key]; ok && !found {
+			res.Files[key] = new.Files[key]
+			if digest, ok := new.Digests[key]; ok {
+				res.Digests[key] = digest
+			}
+		}
+	}
+}
+
+// Diff returns the entries added or changed from old to new,
+// along with the whiteouts deleting the entries new does not have anymore.
+// A directory deleted and created again is made opaque and written with all its content.
 func Diff(old *Snapshot, new *Snapshot) (*Snapshot, error) {
 	res := &Snapshot{
-		Files: make(map[string]*tar.Header),
+		Name:       new.Name,
+		Files:      make(map[string]*tar.Header),
+		hostPrefix: new.hostPrefix,
 	}
+	if new.Digests != nil {
+		res.Digests = make(map[string]string)
+	}
+	recreated := recreatedDirs(old, new)
 	for name, th := range new.Files {
-		old, ok := old.Files[name]
-		if ok {
-			if !th.ModTime.Equal(old.ModTime) || th.Size != old.Size {
-				res.Files[name] = th
-			}
-		} else {
+		_, ok := old.Files[name]
+		if !ok || recreated[name] || below(name, recreated) || changed(old, new, name) {
 			res.Files[name] = th
+			if digest, ok := new.Digests[name]; ok {
+				res.Digests[name] = digest
+			}
 		}
 	}
+	addLinkTargets(res, new)
+	addDeletions(res.Files, old, new, recreated)
 	return res, nil
 }
 
 type SnapshotLayer struct {
-	tarFile        string
-	compressedFile string
+	file           string
+	compression    string
 	diffID         v1.Hash
 	digest         v1.Hash
 	compressedSize int64
@@ -198,11 +548,15 @@ func (s *SnapshotLayer) DiffID() (v1.Hash, error) {
 }
 
 func (s *SnapshotLayer) Compressed() (io.ReadCloser, error) {
-	return os.Open(s.compressedFile)
+	return os.Open(s.file)
 }
 
 func (s *SnapshotLayer) Uncompressed() (io.ReadCloser, error) {
-	return os.Open(s.tarFile)
+	f, err := os.Open(s.file)
+	if err != nil {
+		return nil, err
+	}
+	return newDecompressor(f, s.compression)
 }
 
 func (s *SnapshotLayer) Size() (int64, error) {
@@ -0,0 +1,306 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTree creates the given files, with their parent directories, below dir
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(p), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(p, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTree(t, dir, map[string]string{
+		"keep/a":    "a",
+		"keep/b":    "b",
+		"gone/x":    "x",
+		"gone/y":    "y",
+		"emptied/p": "p",
+		"emptied/q": "q",
+		"c.txt":     "c",
+	})
+	options := &SnapshotOptions{Dest: "/", Digest: true}
+	old, err := NewSnapshot(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, dir, map[string]string{
+		"keep/b":  "b2",
+		"new.txt": "new",
+	})
+	for _, name := range []string{"gone", "emptied/p", "emptied/q", "c.txt"} {
+		err = os.RemoveAll(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	new, err := NewSnapshot(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := Diff(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(diff.Files))
+	for _, th := range diff.Files {
+		names = append(names, th.Name)
+	}
+	sort.Strings(names)
+	// The deleted directory is covered by a single whiteout, the emptied one keeps the lower layers files it never had
+	expected := []string{".wh.c.txt", ".wh.gone", "emptied/.wh.p", "emptied/.wh.q", "keep/b", "new.txt"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected entries %v, got %v", expected, names)
+	}
+}
+
+// diffNames returns the sorted entry names of the diff between two snapshots of dir, change being run in between
+func diffNames(t *testing.T, dir string, change func()) []string {
+	options := &SnapshotOptions{Dest: "/", Digest: true}
+	old, err := NewSnapshot(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	change()
+	new, err := NewSnapshot(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := Diff(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(diff.Files))
+	for _, th := range diff.Files {
+		names = append(names, th.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestDiffSharedDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTree(t, dir, map[string]string{"etc/myapp.conf": "conf"})
+	names := diffNames(t, dir, func() {
+		err := os.Remove(filepath.Join(dir, "etc", "myapp.conf"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	// The image etc directory holds files the snapshot never had, only the removed one may be hidden
+	expected := []string{"etc/.wh.myapp.conf"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected entries %v, got %v", expected, names)
+	}
+}
+
+func TestDiffRecreatedDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTree(t, dir, map[string]string{"cache/a": "a", "cache/b": "b", "cache/sub/c": "c"})
+	names := diffNames(t, dir, func() {
+		// The old directory is moved away first, so the new one cannot reuse its inode
+		err := os.Rename(filepath.Join(dir, "cache"), filepath.Join(dir, "old"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeTree(t, dir, map[string]string{"cache/a": "a"})
+		err = os.RemoveAll(filepath.Join(dir, "old"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	expected := []string{"cache", "cache/.wh..wh..opq", "cache/a"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected entries %v, got %v", expected, names)
+	}
+}
+
+func TestDiffUnchanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTree(t, dir, map[string]string{"a/b": "b"})
+	options := &SnapshotOptions{Dest: "/", Digest: true}
+	old, err := NewSnapshot(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	new, err := NewSnapshot(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := Diff(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Files) != 0 {
+		t.Fatalf("expected no entries, got %d", len(diff.Files))
+	}
+}
+
+// setEpoch sets SOURCE_DATE_EPOCH for the duration of the test, unsetting it when value is empty
+func setEpoch(t *testing.T, value string) {
+	previous, ok := os.LookupEnv("SOURCE_DATE_EPOCH")
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv("SOURCE_DATE_EPOCH", previous)
+		} else {
+			os.Unsetenv("SOURCE_DATE_EPOCH")
+		}
+	})
+	if value == "" {
+		os.Unsetenv("SOURCE_DATE_EPOCH")
+	} else {
+		os.Setenv("SOURCE_DATE_EPOCH", value)
+	}
+}
+
+func TestCreationTime(t *testing.T) {
+	setEpoch(t, "1600000000")
+	created, err := creationTime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Unix() != 1600000000 {
+		t.Errorf("expected the epoch, got %s", created)
+	}
+	setEpoch(t, "soon")
+	if _, err = creationTime(); err == nil {
+		t.Error("expected an invalid SOURCE_DATE_EPOCH to be rejected")
+	}
+}
+
+// snapshotDigest snapshots dir to /app and returns the digest of its layer
+func snapshotDigest(t *testing.T, dir string, options *LayerOptions) string {
+	snapshot, err := NewSnapshot(dir, &SnapshotOptions{Dest: "/app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer, err := snapshot.AsLayer("", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return digest.String()
+}
+
+func TestAsLayerReproducible(t *testing.T) {
+	files := map[string]string{"a": "a", "b/c": "c", "b/d": "d", "e/f/g": "g", "h": "h"}
+	dirs := make([]string, 2)
+	for n := range dirs {
+		dir, err := ioutil.TempDir("", "ocilot-reproducible")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		writeTree(t, dir, files)
+		dirs[n] = dir
+	}
+	later := time.Now().Add(time.Hour)
+	err := filepath.Walk(dirs[1], func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(p, later, later)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshotDigest(t, dirs[0], nil) != snapshotDigest(t, dirs[0], nil) {
+		t.Error("expected the same tree to give the same layer")
+	}
+	epoch := time.Now().Add(-time.Hour).Unix()
+	for _, compression := range []string{CompressionGzip, CompressionZstd, CompressionNone} {
+		options := &LayerOptions{Epoch: &epoch, Compression: compression}
+		if snapshotDigest(t, dirs[0], options) != snapshotDigest(t, dirs[1], options) {
+			t.Errorf("expected trees differing by timestamps to give the same %s layer once clamped", compression)
+		}
+	}
+}
+
+func TestTreeHash(t *testing.T) {
+	files := map[string]string{"a": "a", "b/c": "c"}
+	dirs := make([]string, 2)
+	for n := range dirs {
+		dir, err := ioutil.TempDir("", "ocilot-treehash")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		writeTree(t, dir, files)
+		err = os.Link(filepath.Join(dir, "a"), filepath.Join(dir, "b", "link"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dirs[n] = dir
+	}
+	treeHash := func(dir string) string {
+		snapshot, err := NewSnapshot(dir, &SnapshotOptions{Digest: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return snapshot.TreeHash()
+	}
+	first := treeHash(dirs[0])
+	if first != treeHash(dirs[0]) {
+		t.Error("expected the same tree to give the same hash")
+	}
+	if first != treeHash(dirs[1]) {
+		t.Error("expected the hash not to depend on where the tree is")
+	}
+	writeTree(t, dirs[1], map[string]string{"b/c": "changed"})
+	if first == treeHash(dirs[1]) {
+		t.Error("expected a content change to change the hash")
+	}
+}
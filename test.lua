@@ -13,7 +13,7 @@ local new = alpine:clone(destination)
 
 applyCached(new, cachePrefix, "test:snapshot1", function()
     local files = snapshot("./script_interface")
-    local layer = files:asLayer("./temp.tar")
+    local layer = files:asLayer()
     return { layer }
 end)
 
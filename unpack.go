@@ -0,0 +1,326 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// resolveInRoot returns the host path of the in-image path name below root.
+// Symlinks of its parents are followed as if root was the filesystem root, so that nothing is ever written outside of it.
+func resolveInRoot(root string, name string) (string, error) {
+	parts := strings.Split(name, "/")
+	resolved := ""
+	links := 0
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			if resolved == "." || resolved == "/" {
+				resolved = ""
+			}
+			continue
+		}
+		candidate := path.Join(resolved, part)
+		if len(parts) == 0 {
+			resolved = candidate
+			break
+		}
+		info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(candidate)))
+		if err == nil && info.Mode()&os.ModeSymlink != 0 {
+			links++
+			if links > maxLinkDepth {
+				return "", fmt.Errorf("%s: too many levels of links", name)
+			}
+			target, err := os.Readlink(filepath.Join(root, filepath.FromSlash(candidate)))
+			if err != nil {
+				return "", err
+			}
+			if path.IsAbs(target) {
+				resolved = ""
+			}
+			parts = append(strings.Split(target, "/"), parts...)
+			continue
+		}
+		resolved = candidate
+	}
+	return filepath.Join(root, filepath.FromSlash(resolved)), nil
+}
+
+// unpacker applies layers to a host directory
+type unpacker struct {
+	root   string
+	isRoot bool
+	// dirModes and dirTimes are keyed by the slash separated path of the directories relative to root,
+	// with no symlink in it when it was recorded
+	dirModes map[string]os.FileMode
+	dirTimes map[string]time.Time
+	// written holds the entries of the layer being applied, kept by opaque whiteouts
+	written map[string]bool
+}
+
+// clearDir removes the content of the host directory dir, except the entries written by the current layer
+func (u *unpacker) clearDir(dir string) error {
+	children, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, child := range children {
+		childPath := filepath.Join(dir, child.Name())
+		if u.written[childPath] {
+			if child.IsDir() {
+				err = u.clearDir(childPath)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		err = os.RemoveAll(childPath)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepare removes what stands at target unless it is a directory and keepDir is set
+func prepare(target string, keepDir bool) error {
+	info, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if keepDir && info.IsDir() {
+		return nil
+	}
+	return os.RemoveAll(target)
+}
+
+func (u *unpacker) apply(th *tar.Header, r io.Reader) error {
+	base := path.Base(th.Name)
+	dir := path.Dir(th.Name)
+	if dir == "." {
+		dir = ""
+	}
+	if base == OpaqueWhiteout {
+		target, err := resolveInRoot(u.root, dir)
+		if err != nil {
+			return err
+		}
+		if !isBelow(filepath.ToSlash(target), filepath.ToSlash(u.root)) {
+			return fmt.Errorf("%s: opaque directory outside of %s", th.Name, u.root)
+		}
+		// The directory itself is not resolved by resolveInRoot, a symlink there would lead clearDir anywhere
+		info, err := os.Lstat(target)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s: opaque directory is not a directory", th.Name)
+		}
+		return u.clearDir(target)
+	}
+	if strings.HasPrefix(base, WhiteoutPrefix) {
+		target, err := resolveInRoot(u.root, path.Join(dir, strings.TrimPrefix(base, WhiteoutPrefix)))
+		if err != nil {
+			return err
+		}
+		return os.RemoveAll(target)
+	}
+	target, err := resolveInRoot(u.root, th.Name)
+	if err != nil {
+		return err
+	}
+	if target == u.root {
+		return nil
+	}
+	err = os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(th.Mode) & os.ModePerm
+	switch th.Typeflag {
+	case tar.TypeDir:
+		err = prepare(target, true)
+		if err != nil {
+			return err
+		}
+		err = os.MkdirAll(target, 0755)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(u.root, target)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		u.dirModes[rel] = os.FileMode(th.Mode) & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		u.dirTimes[rel] = th.ModTime
+	case tar.TypeReg, tar.TypeRegA:
+		err = prepare(target, false)
+		if err != nil {
+			return err
+		}
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, r)
+		closeErr := f.Close()
+		if err != nil {
+			return err
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+	case tar.TypeSymlink:
+		err = prepare(target, false)
+		if err != nil {
+			return err
+		}
+		err = os.Symlink(th.Linkname, target)
+		if err != nil {
+			return err
+		}
+	case tar.TypeLink:
+		source, err := resolveInRoot(u.root, th.Linkname)
+		if err != nil {
+			return err
+		}
+		info, err := os.Lstat(source)
+		if err != nil {
+			return fmt.Errorf("hardlink to %s: %v", th.Linkname, err)
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("hardlink to %s: not a regular file", th.Linkname)
+		}
+		err = prepare(target, false)
+		if err != nil {
+			return err
+		}
+		err = os.Link(source, target)
+		if err != nil {
+			return err
+		}
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		if th.Typeflag != tar.TypeFifo && !u.isRoot {
+			log.Debugw("skipping device node when not root", "file", th.Name)
+			return nil
+		}
+		err = prepare(target, false)
+		if err != nil {
+			return err
+		}
+		err = makeNode(target, th)
+		if err != nil {
+			return err
+		}
+	default:
+		log.Warnw("skipping unsupported entry", "file", th.Name, "type", string(th.Typeflag))
+		return nil
+	}
+	u.written[target] = true
+	if u.isRoot {
+		err = os.Lchown(target, th.Uid, th.Gid)
+		if err != nil {
+			return err
+		}
+	}
+	switch th.Typeflag {
+	case tar.TypeReg, tar.TypeRegA, tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		err = os.Chmod(target, os.FileMode(th.Mode)&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky))
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(target, th.ModTime, th.ModTime)
+	}
+	return nil
+}
+
+// Unpack applies all layers of the image to the directory dir, creating it when missing.
+// Entries are kept below dir whatever their name or the symlinks they go through, ownership is only restored when running as root
+// and device nodes are skipped otherwise.
+func (i *Image) Unpack(dir string) error {
+	layers, err := i.img.Layers()
+	if err != nil {
+		return err
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(root, 0755)
+	if err != nil {
+		return err
+	}
+	u := &unpacker{
+		root:     root,
+		isRoot:   os.Geteuid() == 0,
+		dirModes: make(map[string]os.FileMode),
+		dirTimes: make(map[string]time.Time),
+	}
+	for index, layer := range layers {
+		u.written = make(map[string]bool)
+		err = readLayer(layer, u.apply)
+		if err != nil {
+			return fmt.Errorf("unpacking layer %d of %s: %v", index, i, err)
+		}
+	}
+	// Directory permissions and times are restored last, as writing their content would change them.
+	// A later layer may have replaced a parent with a symlink, such directories are skipped.
+	for rel, mode := range u.dirModes {
+		target, err := resolveInRoot(root, rel)
+		if err != nil || target != filepath.Join(root, filepath.FromSlash(rel)) {
+			continue
+		}
+		info, err := os.Lstat(target)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		err = os.Chmod(target, mode)
+		if err != nil {
+			return err
+		}
+		err = os.Chtimes(target, u.dirTimes[rel], u.dirTimes[rel])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"archive/tar"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// unpackDirs returns a root to unpack to and a sibling directory standing for the rest of the host
+func unpackDirs(t *testing.T) (string, string, func()) {
+	dir, err := ioutil.TempDir("", "ocilot-unpack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "root")
+	outside := filepath.Join(dir, "outside")
+	err = os.MkdirAll(outside, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root, outside, func() {
+		_ = os.RemoveAll(dir)
+	}
+}
+
+// treeFiles lists the entries below dir with their type, a trailing "/" for directories and "@" for symlinks
+func treeFiles(t *testing.T, dir string) []string {
+	res := make([]string, 0)
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		switch {
+		case info.IsDir():
+			rel += "/"
+		case info.Mode()&os.ModeSymlink != 0:
+			rel += "@"
+		}
+		res = append(res, rel)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// checkOutside verifies the host directory standing outside of the unpack root was not touched
+func checkOutside(t *testing.T, outside string) {
+	if files := treeFiles(t, outside); strings.Join(files, ",") != "secret" {
+		t.Errorf("expected the outside directory to only hold secret, got %v", files)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(outside, "secret"))
+	if err != nil || string(content) != "secret" {
+		t.Errorf("expected the outside secret to be unchanged, got %q, %v", content, err)
+	}
+}
+
+func TestUnpackSymlinkEscape(t *testing.T) {
+	root, outside, cleanup := unpackDirs(t)
+	defer cleanup()
+	image := layersImage(t, headerLayer(t, []*tar.Header{
+		{Name: "abs", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0777},
+		{Name: "abs/x", Typeflag: tar.TypeReg},
+		{Name: "rel", Typeflag: tar.TypeSymlink, Linkname: "../../../../../../" + filepath.Base(outside), Mode: 0777},
+		{Name: "rel/y", Typeflag: tar.TypeReg},
+	}, map[string]string{"abs/x": "x", "rel/y": "y"}))
+	err := image.Unpack(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOutside(t, outside)
+	// Symlinks are followed as if the root was the filesystem root
+	content, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(outside), "x"))
+	if err != nil || string(content) != "x" {
+		t.Errorf("expected x below the root, got %q, %v", content, err)
+	}
+	content, err = ioutil.ReadFile(filepath.Join(root, filepath.Base(outside), "y"))
+	if err != nil || string(content) != "y" {
+		t.Errorf("expected y below the root, got %q, %v", content, err)
+	}
+}
+
+func TestUnpackDotDot(t *testing.T) {
+	root, outside, cleanup := unpackDirs(t)
+	defer cleanup()
+	image := layersImage(t, headerLayer(t, []*tar.Header{
+		{Name: "../outside/secret", Typeflag: tar.TypeReg},
+		{Name: "a/../../../outside/other", Typeflag: tar.TypeReg},
+	}, map[string]string{"../outside/secret": "overwritten", "a/../../../outside/other": "other"}))
+	err := image.Unpack(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOutside(t, outside)
+	if files := treeFiles(t, root); strings.Join(files, ",") != "outside/,outside/other,outside/secret" {
+		t.Errorf("unexpected unpacked files %v", files)
+	}
+}
+
+func TestUnpackHardlinkEscape(t *testing.T) {
+	for _, headers := range [][]*tar.Header{
+		{{Name: "hl", Typeflag: tar.TypeLink, Linkname: "../outside/secret"}},
+		{
+			{Name: "lnk", Typeflag: tar.TypeSymlink, Mode: 0777},
+			{Name: "zz", Typeflag: tar.TypeLink, Linkname: "lnk/secret"},
+		},
+	} {
+		root, outside, cleanup := unpackDirs(t)
+		if headers[0].Typeflag == tar.TypeSymlink {
+			headers[0].Linkname = outside
+		}
+		image := layersImage(t, headerLayer(t, headers, nil))
+		err := image.Unpack(root)
+		if err == nil {
+			t.Errorf("expected the hardlink %s to be rejected", headers[len(headers)-1].Linkname)
+		}
+		checkOutside(t, outside)
+		secret, _ := os.Stat(filepath.Join(outside, "secret"))
+		link, linkErr := os.Stat(filepath.Join(root, headers[len(headers)-1].Name))
+		if linkErr == nil && os.SameFile(secret, link) {
+			t.Error("expected the outside secret not to be linked")
+		}
+		cleanup()
+	}
+}
+
+func TestUnpackWhiteouts(t *testing.T) {
+	root, _, cleanup := unpackDirs(t)
+	defer cleanup()
+	lower := headerLayer(t, []*tar.Header{
+		{Name: "d", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "d/a", Typeflag: tar.TypeReg},
+		{Name: "d/sub", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "d/sub/b", Typeflag: tar.TypeReg},
+		{Name: "e", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "e/c", Typeflag: tar.TypeReg},
+		{Name: "f", Typeflag: tar.TypeReg},
+	}, nil)
+	upper := headerLayer(t, []*tar.Header{
+		{Name: ".wh.f", Typeflag: tar.TypeReg},
+		{Name: "d/" + OpaqueWhiteout, Typeflag: tar.TypeReg},
+		{Name: "d/new", Typeflag: tar.TypeReg},
+		{Name: "e/.wh.c", Typeflag: tar.TypeReg},
+	}, nil)
+	image := layersImage(t, lower, upper)
+	err := image.Unpack(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files := treeFiles(t, root); strings.Join(files, ",") != "d/,d/new,e/" {
+		t.Errorf("unexpected unpacked files %v", files)
+	}
+}
+
+func TestUnpackOpaqueSymlink(t *testing.T) {
+	root, outside, cleanup := unpackDirs(t)
+	defer cleanup()
+	image := layersImage(t, headerLayer(t, []*tar.Header{
+		{Name: "evil", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0777},
+		{Name: "evil/" + OpaqueWhiteout, Typeflag: tar.TypeReg},
+	}, nil))
+	if err := image.Unpack(root); err == nil {
+		t.Error("expected an opaque symlink to be rejected")
+	}
+	checkOutside(t, outside)
+}
+
+func TestUnpackDirModesSymlink(t *testing.T) {
+	root, outside, cleanup := unpackDirs(t)
+	defer cleanup()
+	err := os.Mkdir(filepath.Join(outside, "sub"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lower := headerLayer(t, []*tar.Header{
+		{Name: "p", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "p/sub", Typeflag: tar.TypeDir, Mode: 0777},
+	}, nil)
+	upper := headerLayer(t, []*tar.Header{
+		{Name: "p", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0777},
+	}, nil)
+	err = layersImage(t, lower, upper).Unpack(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(outside, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("expected the outside directory mode to be kept, got %s", info.Mode())
+	}
+}
@@ -0,0 +1,311 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"archive/tar"
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	passwdFile  = "/etc/passwd"
+	groupFile   = "/etc/group"
+	shadowFile  = "/etc/shadow"
+	gshadowFile = "/etc/gshadow"
+	// firstId is the first uid or gid allocated when none is given
+	firstId = 1000
+)
+
+// User describes an account to add to an image
+type User struct {
+	Name string `json:"name"`
+	// Uid defaults to the first free uid from 1000
+	Uid *int `json:"uid"`
+	// Gid defaults to the group named like the user, created when missing
+	Gid *int `json:"gid"`
+	// Home defaults to /home/<name>, it is created owned by the user when missing
+	Home string `json:"home"`
+	// Shell defaults to /sbin/nologin
+	Shell   string `json:"shell"`
+	Comment string `json:"comment"`
+}
+
+// Group describes a group to add to an image
+type Group struct {
+	Name string `json:"name"`
+	// Gid defaults to the first free gid from 1000
+	Gid     *int     `json:"gid"`
+	Members []string `json:"members"`
+}
+
+// accountFile is a colon separated database such as /etc/passwd
+type accountFile struct {
+	path    string
+	header  *tar.Header
+	lines   []string
+	exists  bool
+	changed bool
+}
+
+func newAccountFile(files map[string]*fileContent, p string) *accountFile {
+	res := &accountFile{path: p}
+	file, ok := files[p]
+	if !ok {
+		return res
+	}
+	res.exists = true
+	res.header = file.header
+	content := strings.TrimRight(string(file.content), "\n")
+	if content != "" {
+		res.lines = strings.Split(content, "\n")
+	}
+	return res
+}
+
+// field returns the column of the entry named name, and whether the entry exists
+func (a *accountFile) field(name string, column int) (string, bool) {
+	for _, line := range a.lines {
+		fields := strings.Split(line, ":")
+		if fields[0] == name {
+			if column < len(fields) {
+				return fields[column], true
+			}
+			return "", true
+		}
+	}
+	return "", false
+}
+
+// usesId reports whether an entry already uses the numeric id in column
+func (a *accountFile) usesId(id int, column int) bool {
+	for _, line := range a.lines {
+		fields := strings.Split(line, ":")
+		if column < len(fields) && fields[column] == strconv.Itoa(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// freeId returns the first id from firstId not used in column
+func (a *accountFile) freeId(column int) int {
+	id := firstId
+	for a.usesId(id, column) {
+		id++
+	}
+	return id
+}
+
+func (a *accountFile) add(fields ...string) {
+	a.lines = append(a.lines, strings.Join(fields, ":"))
+	a.changed = true
+}
+
+// write records the file in builder when changed, keeping the original mode and ownership
+func (a *accountFile) write(builder *LayerBuilder, mode int64) {
+	if !a.changed {
+		return
+	}
+	content := []byte(strings.Join(a.lines, "\n") + "\n")
+	th := &tar.Header{
+		Name:     entryPath(a.path),
+		Typeflag: tar.TypeReg,
+		Mode:     mode,
+	}
+	if a.header != nil {
+		header := *a.header
+		th = &header
+		th.Name = entryPath(a.path)
+	}
+	th.Size = int64(len(content))
+	th.ModTime = entryModTime
+	builder.AddHeader(th, content)
+}
+
+// accounts holds the account databases of an image
+type accounts struct {
+	passwd  *accountFile
+	group   *accountFile
+	shadow  *accountFile
+	gshadow *accountFile
+	files   map[string]*fileContent
+}
+
+func (i *Image) readAccounts(extra ...string) (*accounts, error) {
+	layers, err := i.img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	files, err := readFiles(layers, append([]string{passwdFile, groupFile, shadowFile, gshadowFile}, extra...))
+	if err != nil {
+		return nil, err
+	}
+	return &accounts{
+		passwd:  newAccountFile(files, passwdFile),
+		group:   newAccountFile(files, groupFile),
+		shadow:  newAccountFile(files, shadowFile),
+		gshadow: newAccountFile(files, gshadowFile),
+		files:   files,
+	}, nil
+}
+
+func validAccountName(name string) error {
+	if name == "" || strings.ContainsAny(name, ":\n/") {
+		return fmt.Errorf("invalid account name %q", name)
+	}
+	return nil
+}
+
+// validAccountField checks a free form field of an account line, which cannot hold the field or line separators
+func validAccountField(field string, value string) error {
+	if strings.ContainsAny(value, ":\n") {
+		return fmt.Errorf("invalid %s %q", field, value)
+	}
+	return nil
+}
+
+func (a *accounts) addGroup(group *Group) (int, error) {
+	err := validAccountName(group.Name)
+	if err != nil {
+		return 0, err
+	}
+	for _, member := range group.Members {
+		if member == "" || strings.ContainsAny(member, ":\n/,") {
+			return 0, fmt.Errorf("invalid member %q of group %s", member, group.Name)
+		}
+	}
+	if _, ok := a.group.field(group.Name, 0); ok {
+		return 0, fmt.Errorf("group %s already exists", group.Name)
+	}
+	gid := a.group.freeId(2)
+	if group.Gid != nil {
+		gid = *group.Gid
+		if a.group.usesId(gid, 2) {
+			return 0, fmt.Errorf("gid %d already used", gid)
+		}
+	}
+	members := strings.Join(group.Members, ",")
+	a.group.add(group.Name, "x", strconv.Itoa(gid), members)
+	if a.gshadow.exists {
+		a.gshadow.add(group.Name, "!", "", members)
+	}
+	return gid, nil
+}
+
+func (a *accounts) write(builder *LayerBuilder) {
+	a.passwd.write(builder, 0644)
+	a.group.write(builder, 0644)
+	a.shadow.write(builder, 0640)
+	a.gshadow.write(builder, 0640)
+}
+
+// AddGroup appends a layer adding group to /etc/group, and /etc/gshadow when present
+func (i *Image) AddGroup(group *Group) error {
+	a, err := i.readAccounts()
+	if err != nil {
+		return err
+	}
+	_, err = a.addGroup(group)
+	if err != nil {
+		return fmt.Errorf("cannot add group to %s: %v", i, err)
+	}
+	builder := NewLayerBuilder()
+	a.write(builder)
+	layer, err := builder.Build("", nil)
+	if err != nil {
+		return err
+	}
+	return i.AppendLayer(layer, v1.History{CreatedBy: "ocilot addGroup " + group.Name})
+}
+
+// AddUser appends a layer adding user to /etc/passwd, and /etc/shadow when present, along with its home directory.
+// When no gid is given, the group named like the user is used or created.
+func (i *Image) AddUser(user *User) error {
+	err := validAccountName(user.Name)
+	if err != nil {
+		return err
+	}
+	home := user.Home
+	if home == "" {
+		home = path.Join("/home", user.Name)
+	}
+	shell := user.Shell
+	if shell == "" {
+		shell = "/sbin/nologin"
+	}
+	fields := [][2]string{{"comment", user.Comment}, {"home", home}, {"shell", shell}}
+	for _, field := range fields {
+		err = validAccountField(field[0], field[1])
+		if err != nil {
+			return fmt.Errorf("cannot add user %s: %v", user.Name, err)
+		}
+	}
+	a, err := i.readAccounts(home)
+	if err != nil {
+		return err
+	}
+	if _, ok := a.passwd.field(user.Name, 0); ok {
+		return fmt.Errorf("cannot add user to %s: user %s already exists", i, user.Name)
+	}
+	uid := a.passwd.freeId(2)
+	if user.Uid != nil {
+		uid = *user.Uid
+		if a.passwd.usesId(uid, 2) {
+			return fmt.Errorf("cannot add user to %s: uid %d already used", i, uid)
+		}
+	}
+	var gid int
+	if user.Gid != nil {
+		gid = *user.Gid
+	} else if existing, ok := a.group.field(user.Name, 2); ok {
+		gid, err = strconv.Atoi(existing)
+		if err != nil {
+			return fmt.Errorf("cannot add user to %s: invalid gid %q for group %s", i, existing, user.Name)
+		}
+	} else {
+		group := &Group{Name: user.Name}
+		if !a.group.usesId(uid, 2) {
+			group.Gid = &uid
+		}
+		gid, err = a.addGroup(group)
+		if err != nil {
+			return fmt.Errorf("cannot add user to %s: %v", i, err)
+		}
+	}
+	a.passwd.add(user.Name, "x", strconv.Itoa(uid), strconv.Itoa(gid), user.Comment, home, shell)
+	if a.shadow.exists {
+		a.shadow.add(user.Name, "!", "", "0", "99999", "7", "", "", "")
+	}
+	builder := NewLayerBuilder()
+	a.write(builder)
+	if _, ok := a.files[home]; !ok && home != "/" {
+		err = builder.Add(home, &LayerEntry{Type: EntryDir, Mode: "0755", Uid: uid, Gid: gid})
+		if err != nil {
+			return err
+		}
+	}
+	layer, err := builder.Build("", nil)
+	if err != nil {
+		return err
+	}
+	return i.AppendLayer(layer, v1.History{CreatedBy: "ocilot addUser " + user.Name})
+}
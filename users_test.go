@@ -0,0 +1,96 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"strings"
+	"testing"
+)
+
+func accountsImage(t *testing.T) *Image {
+	image, err := NewScratchImage(v1.Platform{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder := NewLayerBuilder()
+	for name, content := range map[string]string{
+		"/etc/passwd": "root:x:0:0:root:/root:/bin/sh\n",
+		"/etc/group":  "root:x:0:\n",
+	} {
+		err = builder.Add(name, &LayerEntry{Content: []byte(content)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	layer, err := builder.Build("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = image.AddLayer(layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return image
+}
+
+func TestAddUser(t *testing.T) {
+	image := accountsImage(t)
+	uid := 1000
+	err := image.AddUser(&User{Name: "app", Uid: &uid, Comment: "App user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, passwd, err := image.ReadFile("/etc/passwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(passwd), "app:x:1000:1000:App user:/home/app:/sbin/nologin\n") {
+		t.Errorf("unexpected passwd file %q", passwd)
+	}
+	_, group, err := image.ReadFile("/etc/group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(group), "app:x:1000:\n") {
+		t.Errorf("unexpected group file %q", group)
+	}
+}
+
+func TestAddUserInvalidFields(t *testing.T) {
+	image := accountsImage(t)
+	for _, user := range []*User{
+		{Name: "a:b"},
+		{Name: "app", Comment: "x\nroot2:x:0:0::/:/bin/sh"},
+		{Name: "app", Home: "/home/app:/bin/sh"},
+		{Name: "app", Shell: "/bin/sh\n"},
+	} {
+		if err := image.AddUser(user); err == nil {
+			t.Errorf("expected %+v to be rejected", user)
+		}
+	}
+	for _, group := range []*Group{
+		{Name: "grp", Members: []string{"a,b"}},
+		{Name: "grp", Members: []string{"a\nroot2:x:0:"}},
+		{Name: "grp", Members: []string{""}},
+	} {
+		if err := image.AddGroup(group); err == nil {
+			t.Errorf("expected %+v to be rejected", group)
+		}
+	}
+}
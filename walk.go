@@ -0,0 +1,291 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// fileID identifies a file across its hard links
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// readDirBatch is the number of directory entries read at once
+const readDirBatch = 1024
+
+// snapshotWalker collects the entries of a directory tree into a snapshot.
+// Directories are read concurrently, the resulting snapshot does not depend on the scheduling.
+type snapshotWalker struct {
+	root     string
+	absRoot  string
+	options  *SnapshotOptions
+	filter   *snapshotFilter
+	snapshot *Snapshot
+	rels     map[string]string
+	links    map[fileID][]string
+	// excludedDirs are walked only to find re-included entries, they are kept when they lead to one
+	excludedDirs map[string]bool
+
+	lock    sync.Mutex
+	pending sync.WaitGroup
+	slots   chan struct{}
+	err     error
+}
+
+func newSnapshotWalker(root string, options *SnapshotOptions, filter *snapshotFilter, snapshot *Snapshot) (*snapshotWalker, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshotWalker{
+		root:         filepath.Clean(root),
+		absRoot:      strings.Replace(absRoot, "\\", "/", -1),
+		options:      options,
+		filter:       filter,
+		snapshot:     snapshot,
+		rels:         make(map[string]string),
+		links:        make(map[fileID][]string),
+		excludedDirs: make(map[string]bool),
+		slots:        make(chan struct{}, 4*runtime.NumCPU()),
+	}, nil
+}
+
+func (w *snapshotWalker) dereference() bool {
+	return w.options != nil && w.options.Dereference
+}
+
+func (w *snapshotWalker) specialFiles() bool {
+	return w.options != nil && w.options.SpecialFiles
+}
+
+func (w *snapshotWalker) fail(err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *snapshotWalker) failed() bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.err != nil
+}
+
+// run walks the whole tree from the root and waits for all the directories to be read
+func (w *snapshotWalker) run(info os.FileInfo) error {
+	w.visit(w.root, ".", info, nil)
+	w.pending.Wait()
+	return w.err
+}
+
+// visit records filePath and schedules the reading of its content. parents holds the real path of the directories
+// above it, it is only tracked when dereferencing symlinks to detect loops.
+func (w *snapshotWalker) visit(filePath string, rel string, info os.FileInfo, parents []string) {
+	excluded := w.filter.excluded(rel, info.IsDir())
+	if excluded && (!info.IsDir() || w.filter.skipped(rel)) {
+		return
+	}
+	if info.Mode()&os.ModeSymlink != 0 && w.dereference() {
+		target, err := os.Stat(filePath)
+		if err != nil {
+			log.Warnw("keeping dangling symlink", "file", filePath, "error", err)
+		} else {
+			info = target
+		}
+	}
+	if info.IsDir() && w.dereference() {
+		realPath, err := filepath.EvalSymlinks(filePath)
+		if err != nil {
+			w.fail(err)
+			return
+		}
+		for _, parent := range parents {
+			if parent == realPath {
+				log.Warnw("skipping symlink loop", "file", filePath)
+				return
+			}
+		}
+		parents = append(parents[:len(parents):len(parents)], realPath)
+	}
+	th, err := w.header(filePath, rel, info)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+	if th == nil {
+		return
+	}
+	// A root mapped onto the image root has no entry of its own, so it cannot change the image root
+	if th.Name != "" {
+		id, linked := hardlinkID(info)
+		w.lock.Lock()
+		w.snapshot.Files[filePath] = th
+		w.rels[filePath] = rel
+		if linked && th.Typeflag == tar.TypeReg {
+			w.links[id] = append(w.links[id], filePath)
+		}
+		if excluded {
+			w.excludedDirs[filePath] = true
+		}
+		if dirID, ok := fileIdentity(info); ok && th.Typeflag == tar.TypeDir {
+			w.snapshot.dirIDs[filePath] = dirID
+		}
+		w.lock.Unlock()
+	}
+	if th.Typeflag == tar.TypeDir {
+		w.pending.Add(1)
+		go func() {
+			defer w.pending.Done()
+			w.slots <- struct{}{}
+			defer func() {
+				<-w.slots
+			}()
+			err := w.readDir(filePath, rel, parents)
+			if err != nil {
+				w.fail(err)
+			}
+		}()
+	}
+}
+
+// readDir visits the content of a directory, reading it by batches
+func (w *snapshotWalker) readDir(filePath string, rel string, parents []string) error {
+	if w.failed() {
+		return nil
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	for {
+		children, err := f.Readdir(readDirBatch)
+		for _, child := range children {
+			childRel := child.Name()
+			if rel != "." {
+				childRel = rel + "/" + child.Name()
+			}
+			w.visit(filepath.Join(filePath, child.Name()), childRel, child, parents)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// entryName returns the in-image name of the entry at rel
+func (w *snapshotWalker) entryName(rel string) string {
+	if w.options == nil || w.options.Dest == "" {
+		return path.Join(w.absRoot, rel)
+	}
+	return strings.TrimPrefix(path.Join("/", w.options.Dest, rel), "/")
+}
+
+// header builds the tar header of filePath, or returns nil when the file cannot be archived
+func (w *snapshotWalker) header(filePath string, rel string, info os.FileInfo) (*tar.Header, error) {
+	th := &tar.Header{
+		Name:    w.entryName(rel),
+		ModTime: info.ModTime(),
+		Mode:    headerMode(info.Mode()),
+	}
+	mode := info.Mode()
+	switch {
+	case mode.IsRegular():
+		th.Typeflag = tar.TypeReg
+		th.Size = info.Size()
+	case mode.IsDir():
+		th.Typeflag = tar.TypeDir
+	case mode&os.ModeSymlink != 0:
+		linkname, err := os.Readlink(filePath)
+		if err != nil {
+			return nil, err
+		}
+		th.Typeflag = tar.TypeSymlink
+		th.Linkname = linkname
+	case mode&(os.ModeNamedPipe|os.ModeDevice) != 0:
+		if !w.specialFiles() {
+			log.Warnw("skipping special file", "file", filePath, "mode", mode.String())
+			return nil, nil
+		}
+		fh, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return nil, err
+		}
+		th.Typeflag = fh.Typeflag
+		th.Devmajor = fh.Devmajor
+		th.Devminor = fh.Devminor
+	default:
+		log.Warnw("skipping unsupported file", "file", filePath, "mode", mode.String())
+		return nil, nil
+	}
+	if th.Typeflag == tar.TypeReg || th.Typeflag == tar.TypeDir {
+		xattrs, err := readXattrs(filePath)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range xattrs {
+			if th.PAXRecords == nil {
+				th.PAXRecords = make(map[string]string)
+			}
+			th.PAXRecords["SCHILY.xattr."+name] = value
+		}
+	}
+	w.options.applyPermissions(th, rel)
+	return th, nil
+}
+
+// linkHardlinks turns every file sharing its inode with a previous one, in entry name order, into a hard link
+func (w *snapshotWalker) linkHardlinks() {
+	for _, paths := range w.links {
+		present := make([]string, 0, len(paths))
+		for _, p := range paths {
+			if _, ok := w.snapshot.Files[p]; ok {
+				present = append(present, p)
+			}
+		}
+		if len(present) < 2 {
+			continue
+		}
+		sort.Slice(present, func(i, j int) bool {
+			return w.snapshot.Files[present[i]].Name < w.snapshot.Files[present[j]].Name
+		})
+		target := w.snapshot.Files[present[0]].Name
+		for _, p := range present[1:] {
+			th := w.snapshot.Files[p]
+			th.Typeflag = tar.TypeLink
+			th.Linkname = target
+			th.Size = 0
+		}
+	}
+}
@@ -0,0 +1,132 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotDestRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-walk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = os.MkdirAll(filepath.Join(dir, "etc"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "etc", "motd"), []byte("hello"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := NewSnapshot(dir, &SnapshotOptions{Dest: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for filePath, th := range snapshot.Files {
+		if th.Name == "" {
+			t.Errorf("entry of %s has an empty name", filePath)
+		}
+	}
+	layer, err := snapshot.AsLayer("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := layer.Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	names := make([]string, 0)
+	tr := tar.NewReader(r)
+	for {
+		th, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, th.Name)
+	}
+	expected := []string{"etc", "etc/motd"}
+	if len(names) != len(expected) {
+		t.Fatalf("expected entries %v, got %v", expected, names)
+	}
+	for n := range expected {
+		if names[n] != expected[n] {
+			t.Fatalf("expected entries %v, got %v", expected, names)
+		}
+	}
+}
+
+func TestSnapshotConcurrentWalk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-walk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := make(map[string]string)
+	var size int64
+	for d := 0; d < 20; d++ {
+		for f := 0; f < 20; f++ {
+			content := fmt.Sprintf("%d-%d", d, f)
+			files[fmt.Sprintf("d%d/sub/f%d", d, f)] = content
+			size += int64(len(content))
+		}
+	}
+	// A directory larger than a read batch
+	for f := 0; f < readDirBatch+10; f++ {
+		files[fmt.Sprintf("big/f%d", f)] = "x"
+		size++
+	}
+	writeTree(t, dir, files)
+	first, err := NewSnapshot(dir, &SnapshotOptions{Dest: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every file, plus the d*, d*/sub and big directories
+	if expected := len(files) + 20*2 + 1; first.Count() != expected {
+		t.Errorf("expected %d entries, got %d", expected, first.Count())
+	}
+	if first.TotalSize() != size {
+		t.Errorf("expected %d bytes, got %d", size, first.TotalSize())
+	}
+	for n := 0; n < 3; n++ {
+		again, err := NewSnapshot(dir, &SnapshotOptions{Dest: "/"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.TreeHash() != first.TreeHash() {
+			t.Fatal("expected walking the same tree to give the same snapshot")
+		}
+	}
+}
+
+func TestSnapshotWalkError(t *testing.T) {
+	if _, err := NewSnapshot(filepath.Join(os.TempDir(), "ocilot-missing-dir"), nil); err == nil {
+		t.Error("expected a missing directory to fail")
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"archive/tar"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// WhiteoutPrefix marks an entry hiding the file of the same name in the lower layers
+	WhiteoutPrefix = ".wh."
+	// OpaqueWhiteout hides the whole content of its directory in the lower layers
+	OpaqueWhiteout = WhiteoutPrefix + WhiteoutPrefix + ".opq"
+)
+
+// whiteoutHeader returns the entry deleting name from the lower layers
+func whiteoutHeader(name string, th *tar.Header) *tar.Header {
+	return &tar.Header{
+		Name:     path.Join(path.Dir(name), WhiteoutPrefix+path.Base(name)),
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		ModTime:  th.ModTime,
+	}
+}
+
+// opaqueHeader returns the entry hiding the lower layers content of the directory dir
+func opaqueHeader(dir string, th *tar.Header) *tar.Header {
+	return &tar.Header{
+		Name:     path.Join(dir, OpaqueWhiteout),
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		ModTime:  th.ModTime,
+	}
+}
+
+// IsWhiteout reports whether a tar entry name is a whiteout or an opaque directory marker
+func IsWhiteout(name string) bool {
+	return strings.HasPrefix(path.Base(name), WhiteoutPrefix)
+}
+
+// recreatedDirs returns the directories of old that were deleted and created again in new.
+// The snapshot then owns their whole content, which is the only case where hiding the lower layers is safe.
+// The snapshot root is left out, as its destination is usually shared with the image.
+func recreatedDirs(old *Snapshot, new *Snapshot) map[string]bool {
+	res := make(map[string]bool)
+	for key, id := range new.dirIDs {
+		if key == filepath.Clean(old.Name) {
+			continue
+		}
+		if previous, ok := old.dirIDs[key]; ok && previous != id && new.Files[key] != nil {
+			res[key] = true
+		}
+	}
+	return res
+}
+
+// below reports whether key is strictly below one of dirs
+func below(key string, dirs map[string]bool) bool {
+	for parent := filepath.Dir(key); parent != key; key, parent = parent, filepath.Dir(parent) {
+		if dirs[parent] {
+			return true
+		}
+	}
+	return false
+}
+
+// addDeletions records in res the whiteouts needed to go from old to new.
+// Each removed entry gets its own whiteout, as the directories of the snapshot may hold lower layer files it never had.
+// Only a directory the snapshot deleted and created again is marked opaque, entries below a deleted directory,
+// an opaque one or a directory replaced by another file type are covered by their parent.
+func addDeletions(res map[string]*tar.Header, old *Snapshot, new *Snapshot, recreated map[string]bool) {
+	for dir := range recreated {
+		th := new.Files[dir]
+		res[filepath.Join(dir, OpaqueWhiteout)] = opaqueHeader(th.Name, th)
+	}
+	removed := make(map[string]bool)
+	for key := range old.Files {
+		if _, ok := new.Files[key]; !ok {
+			removed[key] = true
+		}
+	}
+	for key := range removed {
+		parent := filepath.Dir(key)
+		if removed[parent] || below(key, recreated) {
+			continue
+		}
+		if th, ok := new.Files[parent]; ok && th.Typeflag != tar.TypeDir {
+			continue
+		}
+		res[key] = whiteoutHeader(old.Files[key].Name, old.Files[key])
+	}
+}
@@ -0,0 +1,68 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+var (
+	workDirLock sync.Mutex
+	workDir     string
+)
+
+// WorkDir returns the temporary directory holding the work files of this run, it is created on first use
+func WorkDir() (string, error) {
+	workDirLock.Lock()
+	defer workDirLock.Unlock()
+	if workDir == "" {
+		dir, err := ioutil.TempDir("", "ocilot-")
+		if err != nil {
+			return "", err
+		}
+		workDir = dir
+	}
+	return workDir, nil
+}
+
+// Cleanup removes the WorkDir and every work file in it
+func Cleanup() error {
+	workDirLock.Lock()
+	defer workDirLock.Unlock()
+	if workDir == "" {
+		return nil
+	}
+	err := os.RemoveAll(workDir)
+	workDir = ""
+	return err
+}
+
+// newWorkFile returns the path of a new empty file in the WorkDir
+func newWorkFile(pattern string) (string, error) {
+	dir, err := WorkDir()
+	if err != nil {
+		return "", err
+	}
+	f, err := ioutil.TempFile(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
@@ -0,0 +1,128 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	err := Cleanup()
+	if err != nil {
+		log.Warnw("cleaning up the work directory", "error", err)
+	}
+	os.Exit(code)
+}
+
+// streamDigest returns the sha256 of the content read from open
+func streamDigest(t *testing.T, open func() (io.ReadCloser, error)) string {
+	r, err := open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+func TestAsLayerSinglePass(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocilot-workdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTree(t, dir, map[string]string{"a": "a", "b/c": "c"})
+	snapshot, err := NewSnapshot(dir, &SnapshotOptions{Dest: "/app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workDir, err := WorkDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := ioutil.ReadDir(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, options := range []*LayerOptions{nil, {Parallel: true}, {Compression: CompressionZstd}} {
+		layer, err := snapshot.AsLayer("", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		digest, err := layer.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if computed := streamDigest(t, layer.Compressed); computed != digest.String() {
+			t.Errorf("expected digest %s, computed %s", digest, computed)
+		}
+		diffID, err := layer.DiffID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if computed := streamDigest(t, layer.Uncompressed); computed != diffID.String() {
+			t.Errorf("expected diffID %s, computed %s", diffID, computed)
+		}
+	}
+	after, err := ioutil.ReadDir(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after)-len(before) != 3 {
+		t.Errorf("expected a single work file per layer, got %d new files", len(after)-len(before))
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	workDir, err := WorkDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	workFile, err := newWorkFile("test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(workFile) != workDir {
+		t.Errorf("expected %s to be in %s", workFile, workDir)
+	}
+	err = Cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(workDir); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed, got %v", workDir, err)
+	}
+	again, err := WorkDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == workDir {
+		t.Error("expected a new work directory after a cleanup")
+	}
+}
@@ -0,0 +1,62 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"strings"
+	"syscall"
+)
+
+// archivedXattr reports whether an extended attribute belongs in a layer: user attributes and file capabilities are,
+// host specific ones like security.selinux or system.* ACLs are not
+func archivedXattr(name string) bool {
+	return strings.HasPrefix(name, "user.") || name == "security.capability"
+}
+
+// readXattrs returns the archived extended attributes of filePath, an empty result when the filesystem does not support them
+func readXattrs(filePath string) (map[string]string, error) {
+	size, err := syscall.Listxattr(filePath, nil)
+	if err == syscall.ENOTSUP || size == 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(filePath, buf)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]string)
+	for _, name := range strings.Split(string(buf[:size]), "\x00") {
+		if !archivedXattr(name) {
+			continue
+		}
+		valueSize, err := syscall.Getxattr(filePath, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, valueSize)
+		valueSize, err = syscall.Getxattr(filePath, name, value)
+		if err != nil {
+			return nil, err
+		}
+		res[name] = string(value[:valueSize])
+	}
+	return res, nil
+}
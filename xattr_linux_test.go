@@ -0,0 +1,65 @@
+/*
+ *    Copyright 2020 Josselin Pujo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package ocilot
+
+import (
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestArchivedXattr(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"user.comment":            true,
+		"security.capability":     true,
+		"security.selinux":        false,
+		"security.ima":            false,
+		"system.posix_acl_access": false,
+		"trusted.overlay.opaque":  false,
+	} {
+		if res := archivedXattr(name); res != expected {
+			t.Errorf("%s: expected %v, got %v", name, expected, res)
+		}
+	}
+}
+
+func TestReadXattrs(t *testing.T) {
+	f, err := ioutil.TempFile("", "ocilot-xattr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+	defer os.Remove(f.Name())
+	err = syscall.Setxattr(f.Name(), "user.ocilot", []byte("value"), 0)
+	if err != nil {
+		t.Skipf("user xattrs not supported: %v", err)
+	}
+	xattrs, err := readXattrs(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if xattrs["user.ocilot"] != "value" {
+		t.Fatalf("expected user.ocilot to be read, got %v", xattrs)
+	}
+	for name := range xattrs {
+		if !archivedXattr(name) {
+			t.Errorf("unexpected xattr %s", name)
+		}
+	}
+}